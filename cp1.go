@@ -11,6 +11,7 @@ import (
 	"math"
 	"math/bits"
 	"os"
+	"strings"
 )
 
 func die(err error) {
@@ -162,6 +163,34 @@ func mustHexDecodeFile(name string) (lines [][]byte) {
 	return
 }
 
+// mustRecordFile reads records made of "key: value" lines.
+// A new record starts whenever a key repeats.
+// Lines starting with # are comments.
+func mustRecordFile(name string) (records []map[string]string) {
+	f, err := os.Open(name)
+	die(err)
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	var record map[string]string
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			log.Fatalf("bad record line in %s: %q", name, line)
+		}
+		if _, ok := record[kv[0]]; ok || record == nil {
+			record = map[string]string{}
+			records = append(records, record)
+		}
+		record[kv[0]] = kv[1]
+	}
+	die(s.Err())
+	return
+}
+
 func MostDecodableLine(filename string) string {
 	lines := mustHexDecodeFile(filename)
 	englishness, result := 0.0, ""
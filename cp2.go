@@ -1,6 +1,11 @@
 package cryptopals
 
-import "crypto/aes"
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"strings"
+)
 
 func PKCSPadding(b []byte, padding int) []byte {
 	remainder := len(b) % padding
@@ -45,3 +50,64 @@ func CBCDecrypt(cipher, key, iv []byte) []byte {
 	}
 	return dst
 }
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	die(err)
+	return b
+}
+
+const (
+	userdataPrefix = "comment1=cooking%20MCs;userdata="
+	userdataSuffix = ";comment2=%20like%20a%20pound%20of%20bacon"
+	adminToken     = ";admin=true;"
+)
+
+var userdataQuoter = strings.NewReplacer(";", "%3B", "=", "%3D")
+
+func makeUserdata(userdata string) []byte {
+	return []byte(userdataPrefix + userdataQuoter.Replace(userdata) + userdataSuffix)
+}
+
+// CBCUserdata encrypts quoted userdata strings under a random key and
+// reports whether a ciphertext decrypts to an admin profile.
+type CBCUserdata struct {
+	key, iv []byte
+}
+
+func NewCBCUserdata() *CBCUserdata {
+	return &CBCUserdata{randomBytes(16), randomBytes(16)}
+}
+
+func (u *CBCUserdata) Encrypt(userdata string) []byte {
+	return CBCEncrypt(makeUserdata(userdata), u.key, u.iv)
+}
+
+func (u *CBCUserdata) Decrypt(cipher []byte) []byte {
+	return CBCDecrypt(cipher, u.key, u.iv)
+}
+
+func (u *CBCUserdata) IsAdmin(cipher []byte) bool {
+	return bytes.Contains(u.Decrypt(cipher), []byte(adminToken))
+}
+
+// flipBytes returns a copy of cipher where the bytes at offset that
+// decrypt (or are XORed into the next block) as have now come out as want.
+func flipBytes(cipher []byte, offset int, have, want string) []byte {
+	r := make([]byte, len(cipher))
+	copy(r, cipher)
+	delta := XorFixed([]byte(have), []byte(want))
+	copy(r[offset:], XorFixed(r[offset:offset+len(delta)], delta))
+	return r
+}
+
+// CBCBitflipAdmin sacrifices the block before the attacker's input
+// to flip the input into an admin token.
+func CBCBitflipAdmin(u *CBCUserdata) []byte {
+	const size = 16
+	filler := strings.Repeat("A", size)
+	known := strings.Repeat("A", len(adminToken))
+	cipher := u.Encrypt(filler + known)
+	return flipBytes(cipher, len(userdataPrefix), known, adminToken)
+}
@@ -100,3 +100,23 @@ func Test10b(t *testing.T) {
 		})
 	}
 }
+
+func Test16(t *testing.T) {
+	u := NewCBCUserdata()
+	if u.IsAdmin(u.Encrypt(adminToken)) {
+		t.Fatal("userdata was not quoted")
+	}
+	cipher := CBCBitflipAdmin(u)
+	if !u.IsAdmin(cipher) {
+		t.Fatalf("not admin: %q", u.Decrypt(cipher))
+	}
+	// The block before the token is sacrificed
+	plain := u.Decrypt(cipher)
+	filler := strings.Repeat("A", 16)
+	start := len(userdataPrefix)
+	if string(plain[start:start+16]) == filler {
+		t.Errorf("block before token unexpectedly intact: %q", plain)
+	}
+	equalString(t, string(plain[:start]), userdataPrefix)
+	equalString(t, string(plain[start+16:start+16+len(adminToken)]), adminToken)
+}
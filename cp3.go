@@ -0,0 +1,29 @@
+package cryptopals
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+)
+
+// CTR encrypts or decrypts input with a little endian 64-bit nonce
+// followed by a little endian 64-bit block counter.
+func CTR(input, key []byte, nonce uint64) []byte {
+	block, err := aes.NewCipher(key)
+	die(err)
+
+	size := block.BlockSize()
+	ctr := make([]byte, size)
+	keystream := make([]byte, size)
+	binary.LittleEndian.PutUint64(ctr, nonce)
+	dst := make([]byte, len(input))
+	for i := 0; i < len(dst); i += size {
+		binary.LittleEndian.PutUint64(ctr[8:], uint64(i/size))
+		block.Encrypt(keystream, ctr)
+		hi := i + size
+		if hi > len(dst) {
+			hi = len(dst)
+		}
+		copy(dst[i:hi], XorFixed(input[i:hi], keystream[:hi-i]))
+	}
+	return dst
+}
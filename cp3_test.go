@@ -0,0 +1,35 @@
+package cryptopals
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func Test18(t *testing.T) {
+	tcs := []struct {
+		name   string
+		input  string
+		key    string
+		nonce  uint64
+		output string
+	}{
+		{
+			name:   "ice",
+			input:  "L77na/nrFsKvynd6HzOoG7GHTLXsTVu9qvY/2syLXzhPweyyMTJULu/6/kXX0KSvoOLSFQ==",
+			key:    "YELLOW SUBMARINE",
+			nonce:  0,
+			output: "Yo, VIP Let's kick it Ice, Ice, baby Ice, Ice, baby ",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			input, err := base64.StdEncoding.DecodeString(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			have := CTR(input, []byte(tc.key), tc.nonce)
+			equalString(t, string(have), tc.output)
+			equalBytes(t, CTR(have, []byte(tc.key), tc.nonce), input)
+		})
+	}
+}
@@ -0,0 +1,694 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/bits"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CTRUserdata is CBCUserdata encrypted with CTR mode instead.
+type CTRUserdata struct {
+	key   []byte
+	nonce uint64
+}
+
+func NewCTRUserdata() *CTRUserdata {
+	return &CTRUserdata{key: randomBytes(16)}
+}
+
+func (u *CTRUserdata) Encrypt(userdata string) []byte {
+	return CTR(makeUserdata(userdata), u.key, u.nonce)
+}
+
+func (u *CTRUserdata) Decrypt(cipher []byte) []byte {
+	return CTR(cipher, u.key, u.nonce)
+}
+
+func (u *CTRUserdata) IsAdmin(cipher []byte) bool {
+	return bytes.Contains(u.Decrypt(cipher), []byte(adminToken))
+}
+
+// CTRBitflipAdmin flips the attacker's own input into an admin token.
+// Unlike CBC, no neighboring block gets garbled along the way.
+func CTRBitflipAdmin(u *CTRUserdata) []byte {
+	known := strings.Repeat("A", len(adminToken))
+	cipher := u.Encrypt(known)
+	return flipBytes(cipher, len(userdataPrefix), known, adminToken)
+}
+
+// KeyIVReceiver decrypts CBC with the key reused as the IV.
+type KeyIVReceiver struct {
+	key []byte
+}
+
+func NewKeyIVReceiver() *KeyIVReceiver {
+	return &KeyIVReceiver{randomBytes(16)}
+}
+
+func (r *KeyIVReceiver) Encrypt(plaintext []byte) []byte {
+	return CBCEncrypt(plaintext, r.key, r.key)
+}
+
+// HighASCIIError is returned by KeyIVReceiver.Decrypt
+// and helpfully includes the offending plaintext.
+type HighASCIIError struct {
+	Plaintext []byte
+}
+
+func (e *HighASCIIError) Error() string {
+	return fmt.Sprintf("invalid plaintext: %q", e.Plaintext)
+}
+
+func (r *KeyIVReceiver) Decrypt(cipher []byte) error {
+	plaintext := CBCDecrypt(cipher, r.key, r.key)
+	for _, c := range plaintext {
+		if c >= 1<<7 {
+			return &HighASCIIError{plaintext}
+		}
+	}
+	return nil
+}
+
+// RecoverKeyIV sends C1, 0, C1 to the receiver.
+// Since P'1 = D(C1) ^ key and P'3 = D(C1) ^ 0,
+// P'1 ^ P'3 is the key.
+func RecoverKeyIV(cipher []byte, decrypt func([]byte) error) []byte {
+	const size = 16
+	if len(cipher) < 3*size {
+		log.Fatalf("ciphertext too short: %d", len(cipher))
+	}
+	c1 := cipher[:size]
+	modified := make([]byte, 0, len(cipher))
+	modified = append(modified, c1...)
+	modified = append(modified, make([]byte, size)...)
+	modified = append(modified, c1...)
+	modified = append(modified, cipher[3*size:]...)
+	herr, ok := decrypt(modified).(*HighASCIIError)
+	if !ok {
+		return nil
+	}
+	p := herr.Plaintext
+	return XorFixed(p[:size], p[2*size:3*size])
+}
+
+const (
+	SHA1Size      = 20
+	sha1BlockSize = 64
+)
+
+var sha1Init = [5]uint32{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476, 0xC3D2E1F0}
+
+// SHA1 is a hash.Hash whose chaining registers can be read and replaced,
+// unlike crypto/sha1.
+type SHA1 struct {
+	h   [5]uint32
+	x   [sha1BlockSize]byte
+	nx  int
+	len uint64
+}
+
+func NewSHA1() *SHA1 {
+	d := new(SHA1)
+	d.Reset()
+	return d
+}
+
+func (d *SHA1) Reset() {
+	d.h = sha1Init
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *SHA1) Size() int { return SHA1Size }
+
+func (d *SHA1) BlockSize() int { return sha1BlockSize }
+
+// State returns the chaining registers and the number of bytes they
+// cover. It reports false if a partial block is still buffered,
+// since those bytes aren't in the registers yet.
+func (d *SHA1) State() (h [5]uint32, length uint64, ok bool) {
+	return d.h, d.len - uint64(d.nx), d.nx == 0
+}
+
+// SetState replaces the chaining registers and the number of bytes processed.
+// Length must be a multiple of the block size.
+func (d *SHA1) SetState(h [5]uint32, length uint64) {
+	if length%sha1BlockSize != 0 {
+		log.Fatalf("length %d is not a multiple of %d", length, sha1BlockSize)
+	}
+	d.h = h
+	d.nx = 0
+	d.len = length
+}
+
+func (d *SHA1) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx == sha1BlockSize {
+			d.block(d.x[:])
+			d.nx = 0
+		}
+	}
+	for len(p) >= sha1BlockSize {
+		d.block(p[:sha1BlockSize])
+		p = p[sha1BlockSize:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return n, nil
+}
+
+// Sum appends the digest to in without changing the state of d.
+func (d *SHA1) Sum(in []byte) []byte {
+	d0 := *d
+	d0.Write(SHA1Padding(d.len))
+	for _, h := range d0.h {
+		in = append(in, byte(h>>24), byte(h>>16), byte(h>>8), byte(h))
+	}
+	return in
+}
+
+func (d *SHA1) block(p []byte) {
+	var w [80]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for i := 16; i < 80; i++ {
+		w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+	}
+	a, b, c, e, f := d.h[0], d.h[1], d.h[2], d.h[3], d.h[4]
+	for i := 0; i < 80; i++ {
+		var fn, k uint32
+		switch {
+		case i < 20:
+			fn, k = b&c|^b&e, 0x5A827999
+		case i < 40:
+			fn, k = b^c^e, 0x6ED9EBA1
+		case i < 60:
+			fn, k = b&c|b&e|c&e, 0x8F1BBCDC
+		default:
+			fn, k = b^c^e, 0xCA62C1D6
+		}
+		t := bits.RotateLeft32(a, 5) + fn + f + k + w[i]
+		a, b, c, e, f = t, a, bits.RotateLeft32(b, 30), c, e
+	}
+	d.h[0] += a
+	d.h[1] += b
+	d.h[2] += c
+	d.h[3] += e
+	d.h[4] += f
+}
+
+// SHA1Padding returns the MD padding SHA-1 appends
+// to a message of length bytes.
+func SHA1Padding(length uint64) []byte {
+	padlen := sha1BlockSize - (length+8)%sha1BlockSize
+	pad := make([]byte, padlen+8)
+	pad[0] = 0x80
+	binary.BigEndian.PutUint64(pad[padlen:], length*8)
+	return pad
+}
+
+func SHA1Sum(b []byte) []byte {
+	d := NewSHA1()
+	d.Write(b)
+	return d.Sum(nil)
+}
+
+// SHA1Registers splits a digest back into chaining registers.
+func SHA1Registers(digest []byte) (h [5]uint32) {
+	for i := range h {
+		h[i] = binary.BigEndian.Uint32(digest[i*4:])
+	}
+	return
+}
+
+// SHA1MAC is the naive secret-prefix MAC SHA1(key || message).
+func SHA1MAC(key, message []byte) []byte {
+	b := make([]byte, 0, len(key)+len(message))
+	b = append(b, key...)
+	b = append(b, message...)
+	return SHA1Sum(b)
+}
+
+// MACVerifier signs and verifies messages under a random key
+// of unknown length.
+type MACVerifier struct {
+	key []byte
+	mac func(key, message []byte) []byte
+}
+
+func newMACVerifier(mac func(key, message []byte) []byte) *MACVerifier {
+	n := int(randomBytes(1)[0])%32 + 1
+	return &MACVerifier{randomBytes(n), mac}
+}
+
+func NewSHA1MACVerifier() *MACVerifier {
+	return newMACVerifier(SHA1MAC)
+}
+
+func (v *MACVerifier) Sign(message []byte) []byte {
+	return v.mac(v.key, message)
+}
+
+func (v *MACVerifier) Verify(message, mac []byte) bool {
+	return subtle.ConstantTimeCompare(v.mac(v.key, message), mac) == 1
+}
+
+// ForgeSHA1MAC extends a known message and MAC with
+// glue padding and suffix. Since the key length is unknown,
+// it tries each length from minKey to maxKey against verify
+// and reports the one that validates.
+func ForgeSHA1MAC(message, mac, suffix []byte, minKey, maxKey int, verify func(message, mac []byte) bool) (forged, forgedMAC []byte, keyLen int, ok bool) {
+	resume := func(mac []byte, length uint64, suffix []byte) []byte {
+		d := NewSHA1()
+		d.SetState(SHA1Registers(mac), length)
+		d.Write(suffix)
+		return d.Sum(nil)
+	}
+	return forgeMAC(message, mac, suffix, minKey, maxKey, verify, SHA1Padding, resume)
+}
+
+func forgeMAC(message, mac, suffix []byte, minKey, maxKey int,
+	verify func(message, mac []byte) bool,
+	padding func(length uint64) []byte,
+	resume func(mac []byte, length uint64, suffix []byte) []byte,
+) (forged, forgedMAC []byte, keyLen int, ok bool) {
+	for keyLen = minKey; keyLen <= maxKey; keyLen++ {
+		glue := padding(uint64(keyLen + len(message)))
+		forged = make([]byte, 0, len(message)+len(glue)+len(suffix))
+		forged = append(forged, message...)
+		forged = append(forged, glue...)
+		forged = append(forged, suffix...)
+
+		forgedMAC = resume(mac, uint64(keyLen+len(message)+len(glue)), suffix)
+		if verify(forged, forgedMAC) {
+			return forged, forgedMAC, keyLen, true
+		}
+	}
+	return nil, nil, 0, false
+}
+
+const (
+	MD4Size      = 16
+	md4BlockSize = 64
+)
+
+var md4Init = [4]uint32{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476}
+
+// MD4 is a hash.Hash whose chaining registers can be read and replaced.
+type MD4 struct {
+	h   [4]uint32
+	x   [md4BlockSize]byte
+	nx  int
+	len uint64
+}
+
+func NewMD4() *MD4 {
+	d := new(MD4)
+	d.Reset()
+	return d
+}
+
+func (d *MD4) Reset() {
+	d.h = md4Init
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *MD4) Size() int { return MD4Size }
+
+func (d *MD4) BlockSize() int { return md4BlockSize }
+
+// State returns the chaining registers and the number of bytes they
+// cover. It reports false if a partial block is still buffered,
+// since those bytes aren't in the registers yet.
+func (d *MD4) State() (h [4]uint32, length uint64, ok bool) {
+	return d.h, d.len - uint64(d.nx), d.nx == 0
+}
+
+// SetState replaces the chaining registers and the number of bytes processed.
+// Length must be a multiple of the block size.
+func (d *MD4) SetState(h [4]uint32, length uint64) {
+	if length%md4BlockSize != 0 {
+		log.Fatalf("length %d is not a multiple of %d", length, md4BlockSize)
+	}
+	d.h = h
+	d.nx = 0
+	d.len = length
+}
+
+func (d *MD4) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx == md4BlockSize {
+			d.h = MD4Block(d.h, MD4Words(d.x[:]))
+			d.nx = 0
+		}
+	}
+	for len(p) >= md4BlockSize {
+		d.h = MD4Block(d.h, MD4Words(p))
+		p = p[md4BlockSize:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return n, nil
+}
+
+// Sum appends the digest to in without changing the state of d.
+func (d *MD4) Sum(in []byte) []byte {
+	d0 := *d
+	d0.Write(MD4Padding(d.len))
+	for _, h := range d0.h {
+		in = append(in, byte(h), byte(h>>8), byte(h>>16), byte(h>>24))
+	}
+	return in
+}
+
+// MD4Words decodes a little endian block into message words.
+func MD4Words(p []byte) (x [16]uint32) {
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(p[i*4:])
+	}
+	return
+}
+
+func MD4F(x, y, z uint32) uint32 { return x&y | ^x&z }
+
+func MD4G(x, y, z uint32) uint32 { return x&y | x&z | y&z }
+
+func MD4H(x, y, z uint32) uint32 { return x ^ y ^ z }
+
+var md4Shifts = [3][4]int{
+	{3, 7, 11, 19},
+	{3, 5, 9, 13},
+	{3, 9, 11, 15},
+}
+
+// MD4Round1 applies the 16 F steps to state s.
+func MD4Round1(s [4]uint32, x *[16]uint32) [4]uint32 {
+	for i := 0; i < 16; i++ {
+		j := (4 - i%4) % 4
+		a, b, c, d := s[j], s[(j+1)%4], s[(j+2)%4], s[(j+3)%4]
+		s[j] = bits.RotateLeft32(a+MD4F(b, c, d)+x[i], md4Shifts[0][i%4])
+	}
+	return s
+}
+
+// MD4Round2 applies the 16 G steps to state s.
+func MD4Round2(s [4]uint32, x *[16]uint32) [4]uint32 {
+	for i := 0; i < 16; i++ {
+		j := (4 - i%4) % 4
+		a, b, c, d := s[j], s[(j+1)%4], s[(j+2)%4], s[(j+3)%4]
+		k := i%4*4 + i/4
+		s[j] = bits.RotateLeft32(a+MD4G(b, c, d)+x[k]+0x5A827999, md4Shifts[1][i%4])
+	}
+	return s
+}
+
+var md4Round3Order = [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+
+// MD4Round3 applies the 16 H steps to state s.
+func MD4Round3(s [4]uint32, x *[16]uint32) [4]uint32 {
+	for i := 0; i < 16; i++ {
+		j := (4 - i%4) % 4
+		a, b, c, d := s[j], s[(j+1)%4], s[(j+2)%4], s[(j+3)%4]
+		k := md4Round3Order[i]
+		s[j] = bits.RotateLeft32(a+MD4H(b, c, d)+x[k]+0x6ED9EBA1, md4Shifts[2][i%4])
+	}
+	return s
+}
+
+// MD4Block runs the compression function on a single block.
+func MD4Block(h [4]uint32, x [16]uint32) [4]uint32 {
+	s := MD4Round1(h, &x)
+	s = MD4Round2(s, &x)
+	s = MD4Round3(s, &x)
+	for i := range h {
+		h[i] += s[i]
+	}
+	return h
+}
+
+// MD4Padding returns the MD padding MD4 appends
+// to a message of length bytes.
+func MD4Padding(length uint64) []byte {
+	padlen := md4BlockSize - (length+8)%md4BlockSize
+	pad := make([]byte, padlen+8)
+	pad[0] = 0x80
+	binary.LittleEndian.PutUint64(pad[padlen:], length*8)
+	return pad
+}
+
+func MD4Sum(b []byte) []byte {
+	d := NewMD4()
+	d.Write(b)
+	return d.Sum(nil)
+}
+
+// MD4Registers splits a digest back into chaining registers.
+func MD4Registers(digest []byte) (h [4]uint32) {
+	for i := range h {
+		h[i] = binary.LittleEndian.Uint32(digest[i*4:])
+	}
+	return
+}
+
+// MD4MAC is the naive secret-prefix MAC MD4(key || message).
+func MD4MAC(key, message []byte) []byte {
+	b := make([]byte, 0, len(key)+len(message))
+	b = append(b, key...)
+	b = append(b, message...)
+	return MD4Sum(b)
+}
+
+func NewMD4MACVerifier() *MACVerifier {
+	return newMACVerifier(MD4MAC)
+}
+
+// ForgeMD4MAC is ForgeSHA1MAC for MD4.
+func ForgeMD4MAC(message, mac, suffix []byte, minKey, maxKey int, verify func(message, mac []byte) bool) (forged, forgedMAC []byte, keyLen int, ok bool) {
+	resume := func(mac []byte, length uint64, suffix []byte) []byte {
+		d := NewMD4()
+		d.SetState(MD4Registers(mac), length)
+		d.Write(suffix)
+		return d.Sum(nil)
+	}
+	return forgeMAC(message, mac, suffix, minKey, maxKey, verify, MD4Padding, resume)
+}
+
+// HMACSHA1 is HMAC built on the package's own SHA1.
+func HMACSHA1(key, message []byte) []byte {
+	if len(key) > sha1BlockSize {
+		key = SHA1Sum(key)
+	}
+	ipad := make([]byte, sha1BlockSize)
+	opad := make([]byte, sha1BlockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	inner := NewSHA1()
+	inner.Write(ipad)
+	inner.Write(message)
+	outer := NewSHA1()
+	outer.Write(opad)
+	outer.Write(inner.Sum(nil))
+	return outer.Sum(nil)
+}
+
+// insecureCompare bails out at the first wrong byte
+// and sleeps for delay after each byte it checks.
+func insecureCompare(a, b []byte, delay time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+		time.Sleep(delay)
+	}
+	return true
+}
+
+// TimingLeakServer answers /test?file=foo&signature=hex
+// with 200 if signature is the HMAC of file and 500 if not.
+type TimingLeakServer struct {
+	Key   []byte
+	Delay time.Duration
+}
+
+func NewTimingLeakServer(delay time.Duration) *TimingLeakServer {
+	return &TimingLeakServer{randomBytes(16), delay}
+}
+
+func (s *TimingLeakServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sig, err := hex.DecodeString(q.Get("signature"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !insecureCompare(HMACSHA1(s.Key, []byte(q.Get("file"))), sig, s.Delay) {
+		http.Error(w, "invalid signature", http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func trimmedMean(ds []time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	trim := (len(sorted) + 1) / 4
+	sorted = sorted[trim : len(sorted)-trim]
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	return sum / time.Duration(len(sorted))
+}
+
+func timeRequest(client *http.Client, url string) (time.Duration, bool) {
+	start := time.Now()
+	rsp, err := client.Get(url)
+	die(err)
+	io.Copy(ioutil.Discard, rsp.Body)
+	rsp.Body.Close()
+	return time.Since(start), rsp.StatusCode == http.StatusOK
+}
+
+// TimingAttackHMAC recovers the HMAC of file from a TimingLeakServer
+// at baseURL. For each byte, every candidate is timed a few times, then
+// the slowest few are timed samples more times until the trimmed mean
+// of the leader clearly stands apart from the runner up.
+// If no leader emerges, the previous byte was probably wrong,
+// so the attack backs up and tries it again, but only so many times
+// in case the server doesn't leak at all.
+func TimingAttackHMAC(client *http.Client, baseURL, file string, samples int) ([]byte, bool) {
+	const (
+		workers       = 32
+		finalists     = 32
+		maxRounds     = 10
+		maxBacktracks = 16
+	)
+	sig := make([]byte, SHA1Size)
+	signedURL := func(sig []byte) string {
+		return fmt.Sprintf("%s/test?file=%s&signature=%x", baseURL, url.QueryEscape(file), sig)
+	}
+	var timings [1 << 8][]time.Duration
+	measure := func(i int, candidates []int, n int) {
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				trial := append([]byte{}, sig...)
+				for c := range jobs {
+					trial[i] = byte(c)
+					d, _ := timeRequest(client, signedURL(trial))
+					mu.Lock()
+					timings[c] = append(timings[c], d)
+					mu.Unlock()
+				}
+			}()
+		}
+		// Shuffle so that slow starts don't favor the same candidates
+		for j := 0; j < n; j++ {
+			for _, k := range mathrand.Perm(len(candidates)) {
+				jobs <- candidates[k]
+			}
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	all := make([]int, len(timings))
+	for c := range all {
+		all[c] = c
+	}
+	// Warm up the connection pool
+	measure(0, all, 1)
+
+	// Estimated delay per correct byte. There is nothing to compare
+	// against at byte 0, so it is taken to be the leader's lead over
+	// the median there, and byte 0 is decided by the runner up alone.
+	var step time.Duration
+
+	backtracks := 0
+	for i := 0; i < len(sig)-1; i++ {
+		for c := range timings {
+			timings[c] = timings[c][:0]
+		}
+		candidates := append([]int{}, all...)
+		var scores [1 << 8]time.Duration
+		rank := func() {
+			for _, c := range candidates {
+				scores[c] = trimmedMean(timings[c])
+			}
+			sort.Slice(candidates, func(a, b int) bool {
+				return scores[candidates[a]] > scores[candidates[b]]
+			})
+		}
+		measure(i, candidates, 3)
+		rank()
+		baseline := scores[candidates[len(candidates)/2]]
+		candidates = candidates[:finalists]
+		decided := false
+		for round := 0; round < maxRounds && !decided; round++ {
+			measure(i, candidates, samples)
+			rank()
+			best, second := scores[candidates[0]], scores[candidates[1]]
+			if i == 0 {
+				step = best - baseline
+			}
+			decided = round > 0 && best-second > step/2 && best-baseline > step/2
+			candidates = candidates[:len(candidates)/2+1]
+		}
+		sig[i] = byte(candidates[0])
+		if !decided {
+			if backtracks++; backtracks > maxBacktracks {
+				return sig, false
+			}
+			sig[i] = 0
+			if i -= 2; i < -1 {
+				i = -1
+			}
+		}
+	}
+	// No need to time the last byte
+	for c := range timings {
+		sig[len(sig)-1] = byte(c)
+		if _, ok := timeRequest(client, signedURL(sig)); ok {
+			return sig, true
+		}
+	}
+	return sig, false
+}
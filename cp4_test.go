@@ -0,0 +1,221 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test26(t *testing.T) {
+	u := NewCTRUserdata()
+	if u.IsAdmin(u.Encrypt(adminToken)) {
+		t.Fatal("userdata was not quoted")
+	}
+	cipher := CTRBitflipAdmin(u)
+	if !u.IsAdmin(cipher) {
+		t.Fatalf("not admin: %q", u.Decrypt(cipher))
+	}
+	// Everything but the flipped token decrypts cleanly
+	expect := userdataPrefix + adminToken + userdataSuffix
+	equalString(t, string(u.Decrypt(cipher)), expect)
+}
+
+func Test27(t *testing.T) {
+	r := NewKeyIVReceiver()
+	cipher := r.Encrypt([]byte(strings.Repeat("Plain ASCII text", 3)))
+	if err := r.Decrypt(cipher); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key := RecoverKeyIV(cipher, r.Decrypt)
+	equalBytes(t, key, r.key)
+}
+
+func TestSHA1(t *testing.T) {
+	for i := 0; i < 300; i++ {
+		input := randomBytes(i)
+		equalBytes(t, SHA1Sum(input), sha1Sum(input))
+
+		// Writes split at random points hash the same
+		d := NewSHA1()
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		d.Write(input[:n.Int64()])
+		d.Write(input[n.Int64():])
+		equalBytes(t, d.Sum(nil), sha1Sum(input))
+	}
+}
+
+func sha1Sum(b []byte) []byte {
+	sum := sha1.Sum(b)
+	return sum[:]
+}
+
+func TestSHA1State(t *testing.T) {
+	input := randomBytes(200)
+	d := NewSHA1()
+	d.Write(input[:128])
+	h, length, ok := d.State()
+	if !ok || length != 128 {
+		t.Fatalf("bad length: %d", length)
+	}
+
+	// A partial block isn't in the registers yet
+	d.Write(input[128:130])
+	if _, length, ok := d.State(); ok || length != 128 {
+		t.Errorf("got length %d, %v with 2 bytes buffered", length, ok)
+	}
+
+	d2 := NewSHA1()
+	d2.SetState(h, length)
+	d2.Write(input[128:])
+	equalBytes(t, d2.Sum(nil), sha1Sum(input))
+
+	// Registers of a finished digest resume after its padding
+	glued := append(append([]byte{}, input...), SHA1Padding(200)...)
+	d3 := NewSHA1()
+	d3.SetState(SHA1Registers(sha1Sum(input)), uint64(len(glued)))
+	d3.Write([]byte("more"))
+	equalBytes(t, d3.Sum(nil), sha1Sum(append(glued, "more"...)))
+}
+
+func Test28(t *testing.T) {
+	v := NewSHA1MACVerifier()
+	message := []byte("We all live in a yellow submarine")
+	mac := v.Sign(message)
+	if !v.Verify(message, mac) {
+		t.Fatal("MAC did not verify")
+	}
+	tampered := append([]byte{}, message...)
+	tampered[0] ^= 1
+	if v.Verify(tampered, mac) {
+		t.Error("tampered message verified")
+	}
+	if v.Verify(message, SHA1MAC(randomBytes(len(v.key)), message)) {
+		t.Error("MAC without key verified")
+	}
+}
+
+func Test29(t *testing.T) {
+	v := NewSHA1MACVerifier()
+	message := []byte("comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon")
+	suffix := []byte(";admin=true")
+	forged, mac, keyLen, ok := ForgeSHA1MAC(message, v.Sign(message), suffix, 1, 32, v.Verify)
+	if !ok {
+		t.Fatal("could not forge MAC")
+	}
+	if keyLen != len(v.key) {
+		t.Errorf("bad key length %d != %d", keyLen, len(v.key))
+	}
+	if !bytes.HasPrefix(forged, message) || !bytes.HasSuffix(forged, suffix) {
+		t.Errorf("bad forgery: %q", forged)
+	}
+	equalBytes(t, mac, v.Sign(forged))
+}
+
+func TestMD4(t *testing.T) {
+	tcs := []struct {
+		input  string
+		output string
+	}{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"a", "bde52cb31de33e46245e05fbdbd6fb24"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"abcdefghijklmnopqrstuvwxyz", "d79e1c308aa5bbcdeea8ed63df412da9"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", "043f8582f241db351ce627e153e7f0e4"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890", "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.input, func(t *testing.T) {
+			equalBytes(t, MD4Sum([]byte(tc.input)), mustHexDecode(tc.output))
+		})
+	}
+}
+
+func TestMD4State(t *testing.T) {
+	input := randomBytes(200)
+	d := NewMD4()
+	d.Write(input[:130])
+	if _, length, ok := d.State(); ok || length != 128 {
+		t.Errorf("got length %d, %v with 2 bytes buffered", length, ok)
+	}
+	d = NewMD4()
+	d.Write(input[:128])
+	h, length, ok := d.State()
+	if !ok || length != 128 {
+		t.Fatalf("bad length: %d", length)
+	}
+
+	d2 := NewMD4()
+	d2.SetState(h, length)
+	d2.Write(input[128:])
+	equalBytes(t, d2.Sum(nil), MD4Sum(input))
+}
+
+func Test30(t *testing.T) {
+	v := NewMD4MACVerifier()
+	message := []byte("comment1=cooking%20MCs;userdata=foo;comment2=%20like%20a%20pound%20of%20bacon")
+	suffix := []byte(";admin=true")
+	forged, mac, keyLen, ok := ForgeMD4MAC(message, v.Sign(message), suffix, 1, 32, v.Verify)
+	if !ok {
+		t.Fatal("could not forge MAC")
+	}
+	if keyLen != len(v.key) {
+		t.Errorf("bad key length %d != %d", keyLen, len(v.key))
+	}
+	if !bytes.HasPrefix(forged, message) || !bytes.HasSuffix(forged, suffix) {
+		t.Errorf("bad forgery: %q", forged)
+	}
+	equalBytes(t, mac, v.Sign(forged))
+}
+
+func TestHMACSHA1(t *testing.T) {
+	for _, n := range []int{0, 16, 64, 100} {
+		key := randomBytes(n)
+		message := randomBytes(n * 3)
+		mac := hmac.New(sha1.New, key)
+		mac.Write(message)
+		equalBytes(t, HMACSHA1(key, message), mac.Sum(nil))
+	}
+}
+
+func Test31(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing attack is slow")
+	}
+	s := NewTimingLeakServer(time.Millisecond)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	client := &http.Client{
+		Transport: &http.Transport{MaxIdleConnsPerHost: 1 << 8},
+	}
+
+	sig, ok := TimingAttackHMAC(client, srv.URL, "foo", 5)
+	if !ok {
+		t.Errorf("bad signature %x", sig)
+	}
+	equalBytes(t, sig, HMACSHA1(s.Key, []byte("foo")))
+}
+
+func TestTimingAttackNoLeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing attack is slow")
+	}
+	s := NewTimingLeakServer(0)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	client := &http.Client{
+		Transport: &http.Transport{MaxIdleConnsPerHost: 1 << 8},
+	}
+
+	// Nothing to find, but it has to give up eventually
+	if sig, ok := TimingAttackHMAC(client, srv.URL, "foo", 1); ok {
+		t.Errorf("found signature %x without a leak", sig)
+	}
+}
@@ -0,0 +1,1083 @@
+package cryptopals
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DHGroup holds Diffie-Hellman domain parameters.
+type DHGroup struct {
+	P, G *big.Int
+}
+
+func mustBigHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		log.Fatalf("bad hex number %q", s)
+	}
+	return n
+}
+
+var (
+	// NISTGroup is the 1536-bit MODP group used by the cryptopals challenges.
+	NISTGroup = &DHGroup{
+		P: mustBigHex("ffffffffffffffffc90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b139b22514a08798e3404ddef9519b3cd3a431b302b0a6df25f14374fe1356d6d51c245e485b576625e7ec6f44c42e9a637ed6b0bff5cb6f406b7edee386bfb5a899fa5ae9f24117c4b1fe649286651ece45b3dc2007cb8a163bf0598da48361c55d39a69163fa8fd24cf5f83655d23dca3ad961c62f356208552bb9ed529077096966d670c354e4abc9804f1746c08ca237327ffffffffffffffff"),
+		G: big.NewInt(2),
+	}
+	// ToyGroup is small enough to check by hand.
+	ToyGroup = &DHGroup{P: big.NewInt(37), G: big.NewInt(5)}
+)
+
+// GenerateKey returns a random private key in [1, p-1) and its public key.
+func (g *DHGroup) GenerateKey() (priv, pub *big.Int) {
+	max := new(big.Int).Sub(g.P, big.NewInt(2))
+	priv, err := rand.Int(rand.Reader, max)
+	die(err)
+	priv.Add(priv, big.NewInt(1))
+	return priv, g.PublicKey(priv)
+}
+
+func (g *DHGroup) PublicKey(priv *big.Int) *big.Int {
+	return new(big.Int).Exp(g.G, priv, g.P)
+}
+
+func (g *DHGroup) SharedSecret(priv, otherPub *big.Int) *big.Int {
+	return new(big.Int).Exp(otherPub, priv, g.P)
+}
+
+// DHKey derives a 16 byte AES key from a shared secret.
+func DHKey(secret *big.Int) []byte {
+	return SHA1Sum(secret.Bytes())[:16]
+}
+
+// Channel carries whole messages between two parties.
+type Channel interface {
+	Send(msg []byte) error
+	Recv() ([]byte, error)
+	Close() error
+}
+
+type pipeEnd struct {
+	in  <-chan []byte
+	out chan<- []byte
+	// done is closed by Close and peerDone by the other end.
+	// The message channels stay open so late Sends can't panic.
+	done, peerDone chan struct{}
+	once           sync.Once
+}
+
+// Pipe returns two connected Channels backed by Go channels.
+func Pipe() (Channel, Channel) {
+	ab := make(chan []byte, 1)
+	ba := make(chan []byte, 1)
+	aDone := make(chan struct{})
+	bDone := make(chan struct{})
+	return &pipeEnd{in: ba, out: ab, done: aDone, peerDone: bDone},
+		&pipeEnd{in: ab, out: ba, done: bDone, peerDone: aDone}
+}
+
+func (p *pipeEnd) Send(msg []byte) error {
+	select {
+	case <-p.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	select {
+	case p.out <- append([]byte{}, msg...):
+		return nil
+	case <-p.done:
+		return io.ErrClosedPipe
+	case <-p.peerDone:
+		// Nobody is left to read it
+		return io.ErrClosedPipe
+	}
+}
+
+func (p *pipeEnd) Recv() ([]byte, error) {
+	select {
+	case msg := <-p.in:
+		return msg, nil
+	case <-p.peerDone:
+		// Deliver anything sent before the close
+		select {
+		case msg := <-p.in:
+			return msg, nil
+		default:
+			return nil, io.EOF
+		}
+	}
+}
+
+func (p *pipeEnd) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+type connChannel struct {
+	conn net.Conn
+}
+
+// NewConnChannel frames messages over conn with a 4 byte length prefix.
+func NewConnChannel(conn net.Conn) Channel {
+	return connChannel{conn}
+}
+
+func (c connChannel) Send(msg []byte) error {
+	buf := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	copy(buf[4:], msg)
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func (c connChannel) Recv() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (c connChannel) Close() error {
+	return c.conn.Close()
+}
+
+// TCPPipe returns two Channels connected over a local TCP socket.
+func TCPPipe() (Channel, Channel, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	errc := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		accepted <- conn
+	}()
+	a, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		return nil, nil, err
+	}
+	select {
+	case b := <-accepted:
+		return NewConnChannel(a), NewConnChannel(b), nil
+	case err := <-errc:
+		a.Close()
+		return nil, nil, err
+	}
+}
+
+// Direction tells a Middlebox which way a message is headed.
+type Direction int
+
+const (
+	AToB Direction = iota
+	BToA
+)
+
+// Middlebox sees every message relayed between two parties
+// and returns the messages to pass along in its place.
+// Returning nil drops the message and returning extra messages injects them.
+type Middlebox func(dir Direction, msg []byte) [][]byte
+
+// Relay passes messages between a and b through mb
+// until either side closes, then closes the other side.
+// A side that closes while messages are still headed
+// its way counts as closing, not as an error.
+func Relay(a, b Channel, mb Middlebox) error {
+	errc := make(chan error, 2)
+	pump := func(dir Direction, from, to Channel) {
+		defer to.Close()
+		for {
+			msg, err := from.Recv()
+			if err == io.EOF {
+				errc <- nil
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			for _, out := range mb(dir, msg) {
+				if err = to.Send(out); err == io.ErrClosedPipe {
+					errc <- nil
+					return
+				}
+				if err != nil {
+					errc <- err
+					return
+				}
+			}
+		}
+	}
+	go pump(AToB, a, b)
+	go pump(BToA, b, a)
+	err1, err2 := <-errc, <-errc
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// Passthrough is a Middlebox that changes nothing.
+func Passthrough(dir Direction, msg []byte) [][]byte {
+	return [][]byte{msg}
+}
+
+// MITMPipe returns Channels for A and B with mb relaying between them.
+func MITMPipe(mb Middlebox) (a, b Channel) {
+	a, mitmA := Pipe()
+	mitmB, b := Pipe()
+	go Relay(mitmA, mitmB, mb)
+	return a, b
+}
+
+// EncodeInts packs numbers into a single message.
+func EncodeInts(ns ...*big.Int) []byte {
+	var buf bytes.Buffer
+	die(gob.NewEncoder(&buf).Encode(ns))
+	return buf.Bytes()
+}
+
+func DecodeInts(msg []byte) ([]*big.Int, error) {
+	var ns []*big.Int
+	err := gob.NewDecoder(bytes.NewReader(msg)).Decode(&ns)
+	return ns, err
+}
+
+func recvInts(ch Channel, n int) ([]*big.Int, error) {
+	msg, err := ch.Recv()
+	if err != nil {
+		return nil, err
+	}
+	ns, err := DecodeInts(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(ns) != n {
+		return nil, fmt.Errorf("got %d numbers; want %d", len(ns), n)
+	}
+	return ns, nil
+}
+
+// sealCBC encrypts msg under key with a random IV appended.
+func sealCBC(msg, key []byte) []byte {
+	iv := randomBytes(16)
+	return append(CBCEncrypt(msg, key, iv), iv...)
+}
+
+func openCBC(sealed, key []byte) ([]byte, error) {
+	if len(sealed) < 32 || len(sealed)%16 != 0 {
+		return nil, fmt.Errorf("bad ciphertext length %d", len(sealed))
+	}
+	cipher, iv := sealed[:len(sealed)-16], sealed[len(sealed)-16:]
+	return bytes.TrimRight(CBCDecrypt(cipher, key, iv), "\x04"), nil
+}
+
+var ErrDHGroup = errors.New("dh: bad group parameters")
+
+// receivedGroup checks a group from the other side before GenerateKey
+// can choke on it. It needs p > 3 and 1 <= g <= p,
+// so the degenerate g = 1, p - 1, and p still get through.
+func receivedGroup(p, g *big.Int) (*DHGroup, error) {
+	if p == nil || g == nil || p.Cmp(big.NewInt(3)) <= 0 ||
+		g.Sign() <= 0 || g.Cmp(p) > 0 {
+		return nil, ErrDHGroup
+	}
+	return &DHGroup{P: p, G: g}, nil
+}
+
+// DHEchoClient negotiates a key with the echo bot on ch,
+// sends it msg, and returns the decrypted echo.
+func DHEchoClient(ch Channel, group *DHGroup, msg []byte) ([]byte, error) {
+	a, A := group.GenerateKey()
+	if err := ch.Send(EncodeInts(group.P, group.G, A)); err != nil {
+		return nil, err
+	}
+	ns, err := recvInts(ch, 1)
+	if err != nil {
+		return nil, err
+	}
+	return echoRequest(ch, DHKey(group.SharedSecret(a, ns[0])), msg)
+}
+
+func echoRequest(ch Channel, key, msg []byte) ([]byte, error) {
+	if err := ch.Send(sealCBC(msg, key)); err != nil {
+		return nil, err
+	}
+	reply, err := ch.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return openCBC(reply, key)
+}
+
+// DHEchoBot answers a single DHEchoClient on ch.
+func DHEchoBot(ch Channel) error {
+	ns, err := recvInts(ch, 3)
+	if err != nil {
+		return err
+	}
+	group, err := receivedGroup(ns[0], ns[1])
+	if err != nil {
+		return err
+	}
+	b, B := group.GenerateKey()
+	if err = ch.Send(EncodeInts(B)); err != nil {
+		return err
+	}
+	return echoReply(ch, DHKey(group.SharedSecret(b, ns[2])))
+}
+
+func echoReply(ch Channel, key []byte) error {
+	sealed, err := ch.Recv()
+	if err != nil {
+		return err
+	}
+	msg, err := openCBC(sealed, key)
+	if err != nil {
+		return err
+	}
+	return ch.Send(sealCBC(msg, key))
+}
+
+// DHNegotiatedEchoClient is DHEchoClient, except that it proposes p and g
+// first and then uses whatever group the echo bot acknowledges.
+func DHNegotiatedEchoClient(ch Channel, proposal *DHGroup, msg []byte) ([]byte, error) {
+	if err := ch.Send(EncodeInts(proposal.P, proposal.G)); err != nil {
+		return nil, err
+	}
+	ns, err := recvInts(ch, 2)
+	if err != nil {
+		return nil, err
+	}
+	group, err := receivedGroup(ns[0], ns[1])
+	if err != nil {
+		return nil, err
+	}
+	a, A := group.GenerateKey()
+	if err = ch.Send(EncodeInts(A)); err != nil {
+		return nil, err
+	}
+	if ns, err = recvInts(ch, 1); err != nil {
+		return nil, err
+	}
+	return echoRequest(ch, DHKey(group.SharedSecret(a, ns[0])), msg)
+}
+
+// DHNegotiatedEchoBot answers a single DHNegotiatedEchoClient on ch.
+func DHNegotiatedEchoBot(ch Channel) error {
+	ns, err := recvInts(ch, 2)
+	if err != nil {
+		return err
+	}
+	group, err := receivedGroup(ns[0], ns[1])
+	if err != nil {
+		return err
+	}
+	if err = ch.Send(EncodeInts(group.P, group.G)); err != nil {
+		return err
+	}
+	if ns, err = recvInts(ch, 1); err != nil {
+		return err
+	}
+	b, B := group.GenerateKey()
+	if err = ch.Send(EncodeInts(B)); err != nil {
+		return err
+	}
+	return echoReply(ch, DHKey(group.SharedSecret(b, ns[0])))
+}
+
+// KeyFixingAttack is a Middlebox for the DH echo protocol.
+// It replaces both public keys with p, so both sides compute
+// a shared secret of 0, and it records every message it decrypts.
+type KeyFixingAttack struct {
+	mu         sync.Mutex
+	p          *big.Int
+	Plaintexts [][]byte
+}
+
+func (k *KeyFixingAttack) Middlebox(dir Direction, msg []byte) [][]byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if ns, err := DecodeInts(msg); err == nil {
+		switch {
+		case dir == AToB && len(ns) == 3:
+			k.p = ns[0]
+			return [][]byte{EncodeInts(ns[0], ns[1], k.p)}
+		case dir == BToA && len(ns) == 1 && k.p != nil:
+			return [][]byte{EncodeInts(k.p)}
+		}
+	}
+	if plaintext, err := openCBC(msg, DHKey(new(big.Int))); err == nil {
+		k.Plaintexts = append(k.Plaintexts, plaintext)
+	}
+	return [][]byte{msg}
+}
+
+// Substitutes for g that trap DH in a tiny subgroup.
+func GOne(p *big.Int) *big.Int { return big.NewInt(1) }
+
+func GP(p *big.Int) *big.Int { return new(big.Int).Set(p) }
+
+func GPMinusOne(p *big.Int) *big.Int { return new(big.Int).Sub(p, big.NewInt(1)) }
+
+// MaliciousGAttack is a Middlebox for the negotiated DH echo protocol.
+// It replaces the proposed g with G(p), which both sides then agree to,
+// and it records every message it decrypts.
+type MaliciousGAttack struct {
+	G          func(p *big.Int) *big.Int
+	mu         sync.Mutex
+	p          *big.Int
+	pubs       []*big.Int
+	Plaintexts [][]byte
+}
+
+func (m *MaliciousGAttack) Middlebox(dir Direction, msg []byte) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ns, err := DecodeInts(msg); err == nil {
+		switch {
+		case dir == AToB && len(ns) == 2 && m.p == nil:
+			m.p = ns[0]
+			return [][]byte{EncodeInts(m.p, m.G(m.p))}
+		case len(ns) == 1:
+			m.pubs = append(m.pubs, ns[0])
+		}
+		return [][]byte{msg}
+	}
+	if len(m.pubs) == 2 {
+		key := DHKey(PredictSubgroupSecret(m.p, m.pubs[0], m.pubs[1]))
+		if plaintext, err := openCBC(msg, key); err == nil {
+			m.Plaintexts = append(m.Plaintexts, plaintext)
+		}
+	}
+	return [][]byte{msg}
+}
+
+// PredictSubgroupSecret returns the secret for public keys A and B
+// generated from g = 1, g = p, or g = p - 1.
+// For g = 1 the secret is always 1 and for g = p it is always 0.
+// For g = p - 1 it is either 1 or p - 1 depending on the parity of ab,
+// but the public keys give the parities of a and b away:
+// the secret is p - 1 only if both A and B are p - 1.
+func PredictSubgroupSecret(p, A, B *big.Int) *big.Int {
+	one := big.NewInt(1)
+	A = new(big.Int).Mod(A, p)
+	B = new(big.Int).Mod(B, p)
+	switch {
+	case A.Sign() == 0 || B.Sign() == 0:
+		return new(big.Int)
+	case A.Cmp(one) == 0 || B.Cmp(one) == 0:
+		return one
+	default:
+		return new(big.Int).Sub(p, one)
+	}
+}
+
+func sendGob(ch Channel, v interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	return ch.Send(buf.Bytes())
+}
+
+func recvGob(ch Channel, v interface{}) error {
+	msg, err := ch.Recv()
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(msg)).Decode(v)
+}
+
+// SRPParams are the values client and server agree on ahead of time.
+type SRPParams struct {
+	N, G, K *big.Int
+}
+
+// NewSRPParams derives the SRP-6a multiplier k = H(N || PAD(g)).
+func NewSRPParams(N, g *big.Int) *SRPParams {
+	k := sha256Int(N.Bytes(), leftPad(g.Bytes(), len(N.Bytes())))
+	return &SRPParams{N: N, G: g, K: k}
+}
+
+var NISTSRP = NewSRPParams(NISTGroup.P, big.NewInt(2))
+
+var ErrSRPAuth = errors.New("srp: authentication failed")
+
+type srpHello struct {
+	Email string
+	A     *big.Int
+}
+
+type srpChallenge struct {
+	Salt []byte
+	B    *big.Int
+}
+
+type srpProof struct {
+	MAC []byte
+}
+
+type srpResult struct {
+	OK bool
+}
+
+func sha256Int(parts ...[]byte) *big.Int {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
+
+func hmacSHA256(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// srpProofMAC is HMAC-SHA256(SHA256(S), salt).
+func srpProofMAC(S *big.Int, salt []byte) []byte {
+	K := sha256.Sum256(S.Bytes())
+	return hmacSHA256(K[:], salt)
+}
+
+// srpPublic reports whether x can be a public value from the other side.
+// gob leaves out nil fields, so hostile peers can send them.
+func srpPublic(x *big.Int) bool {
+	return x != nil && x.Sign() >= 0
+}
+
+type srpUser struct {
+	salt []byte
+	v    *big.Int
+}
+
+// SRPServer authenticates users with SRP-6a.
+// If Validate is set, it rejects clients whose A is a multiple of N.
+type SRPServer struct {
+	*SRPParams
+	Validate bool
+	users    map[string]srpUser
+}
+
+func NewSRPServer(params *SRPParams) *SRPServer {
+	return &SRPServer{SRPParams: params, users: map[string]srpUser{}}
+}
+
+// Register stores a salt and verifier for email.
+// The password itself is not kept.
+func (s *SRPServer) Register(email, password string) {
+	salt := randomBytes(16)
+	x := sha256Int(salt, []byte(password))
+	s.users[email] = srpUser{salt, new(big.Int).Exp(s.G, x, s.N)}
+}
+
+func (s *SRPServer) randomExponent() *big.Int {
+	b, err := rand.Int(rand.Reader, s.N)
+	die(err)
+	return b
+}
+
+// Serve handles a single login attempt on ch and reports whether it succeeded.
+func (s *SRPServer) Serve(ch Channel) error {
+	var hello srpHello
+	if err := recvGob(ch, &hello); err != nil {
+		return err
+	}
+	if !srpPublic(hello.A) {
+		return ErrSRPAuth
+	}
+	user, ok := s.users[hello.Email]
+	if !ok {
+		// Don't reveal whether the user exists
+		user = srpUser{randomBytes(16), s.randomExponent()}
+	}
+	if s.Validate && new(big.Int).Mod(hello.A, s.N).Sign() == 0 {
+		ok = false
+	}
+
+	b := s.randomExponent()
+	B := new(big.Int).Mul(s.K, user.v)
+	B.Add(B, new(big.Int).Exp(s.G, b, s.N))
+	B.Mod(B, s.N)
+	if err := sendGob(ch, srpChallenge{user.salt, B}); err != nil {
+		return err
+	}
+
+	u := sha256Int(hello.A.Bytes(), B.Bytes())
+	S := new(big.Int).Exp(user.v, u, s.N)
+	S.Mul(S, hello.A)
+	S.Exp(S, b, s.N)
+
+	var proof srpProof
+	if err := recvGob(ch, &proof); err != nil {
+		return err
+	}
+	ok = ok && hmac.Equal(proof.MAC, srpProofMAC(S, user.salt))
+	if err := sendGob(ch, srpResult{ok}); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSRPAuth
+	}
+	return nil
+}
+
+// srpSendProof sends the client's proof for S and waits for the verdict.
+func srpSendProof(ch Channel, S *big.Int, salt []byte) error {
+	if err := sendGob(ch, srpProof{srpProofMAC(S, salt)}); err != nil {
+		return err
+	}
+	var result srpResult
+	if err := recvGob(ch, &result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return ErrSRPAuth
+	}
+	return nil
+}
+
+// SRPLogin logs in to an SRPServer on ch.
+func SRPLogin(ch Channel, params *SRPParams, email, password string) error {
+	a, err := rand.Int(rand.Reader, params.N)
+	die(err)
+	A := new(big.Int).Exp(params.G, a, params.N)
+	if err = sendGob(ch, srpHello{email, A}); err != nil {
+		return err
+	}
+	var challenge srpChallenge
+	if err = recvGob(ch, &challenge); err != nil {
+		return err
+	}
+	// SRP-6a clients give up on B % N == 0 or u == 0
+	if !srpPublic(challenge.B) || new(big.Int).Mod(challenge.B, params.N).Sign() == 0 {
+		return ErrSRPAuth
+	}
+	u := sha256Int(A.Bytes(), challenge.B.Bytes())
+	if u.Sign() == 0 {
+		return ErrSRPAuth
+	}
+	x := sha256Int(challenge.Salt, []byte(password))
+	// S = (B - k * g**x) ** (a + u * x) % N
+	base := new(big.Int).Exp(params.G, x, params.N)
+	base.Mul(base, params.K)
+	base.Sub(challenge.B, base)
+	base.Mod(base, params.N)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, a)
+	S := new(big.Int).Exp(base, exp, params.N)
+	return srpSendProof(ch, S, challenge.Salt)
+}
+
+// SRPZeroKeyLogin logs in to an SRPServer as email without the password
+// by sending A = 0, N, 2N, or any other multiple of N.
+// The server computes S = (A * v**u) ** b % N = 0,
+// so the client can prove it knows S without knowing v.
+func SRPZeroKeyLogin(ch Channel, email string, A *big.Int) error {
+	if err := sendGob(ch, srpHello{email, A}); err != nil {
+		return err
+	}
+	var challenge srpChallenge
+	if err := recvGob(ch, &challenge); err != nil {
+		return err
+	}
+	return srpSendProof(ch, new(big.Int), challenge.Salt)
+}
+
+type simpleSRPChallenge struct {
+	Salt []byte
+	B, U *big.Int
+}
+
+// SimpleSRPServer runs a simplified SRP where B = g**b
+// and u is a random 128-bit number instead of a hash.
+type SimpleSRPServer struct {
+	SRPServer
+}
+
+func NewSimpleSRPServer(params *SRPParams) *SimpleSRPServer {
+	return &SimpleSRPServer{*NewSRPServer(params)}
+}
+
+// Serve handles a single login attempt on ch and reports whether it succeeded.
+func (s *SimpleSRPServer) Serve(ch Channel) error {
+	var hello srpHello
+	if err := recvGob(ch, &hello); err != nil {
+		return err
+	}
+	if !srpPublic(hello.A) {
+		return ErrSRPAuth
+	}
+	user, ok := s.users[hello.Email]
+	if !ok {
+		user = srpUser{randomBytes(16), s.randomExponent()}
+	}
+
+	b := s.randomExponent()
+	B := new(big.Int).Exp(s.G, b, s.N)
+	u := new(big.Int).SetBytes(randomBytes(16))
+	if err := sendGob(ch, simpleSRPChallenge{user.salt, B, u}); err != nil {
+		return err
+	}
+
+	// S = (A * v**u) ** b % N
+	S := new(big.Int).Exp(user.v, u, s.N)
+	S.Mul(S, hello.A)
+	S.Exp(S, b, s.N)
+
+	var proof srpProof
+	if err := recvGob(ch, &proof); err != nil {
+		return err
+	}
+	ok = ok && hmac.Equal(proof.MAC, srpProofMAC(S, user.salt))
+	if err := sendGob(ch, srpResult{ok}); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSRPAuth
+	}
+	return nil
+}
+
+// SimpleSRPLogin logs in to a SimpleSRPServer on ch.
+func SimpleSRPLogin(ch Channel, params *SRPParams, email, password string) error {
+	a, err := rand.Int(rand.Reader, params.N)
+	die(err)
+	A := new(big.Int).Exp(params.G, a, params.N)
+	if err = sendGob(ch, srpHello{email, A}); err != nil {
+		return err
+	}
+	var challenge simpleSRPChallenge
+	if err = recvGob(ch, &challenge); err != nil {
+		return err
+	}
+	if !srpPublic(challenge.B) || !srpPublic(challenge.U) {
+		return ErrSRPAuth
+	}
+
+	// S = B ** (a + u * x) % N
+	x := sha256Int(challenge.Salt, []byte(password))
+	exp := new(big.Int).Mul(challenge.U, x)
+	exp.Add(exp, a)
+	S := new(big.Int).Exp(challenge.B, exp, params.N)
+	return srpSendProof(ch, S, challenge.Salt)
+}
+
+// SimpleSRPCapture is what a SimpleSRPMITM learns from a login.
+type SimpleSRPCapture struct {
+	Email string
+	A     *big.Int
+	MAC   []byte
+}
+
+// SimpleSRPMITM poses as a SimpleSRPServer and picks
+// b = 1, B = g, u = 1, and an empty salt,
+// so that S = A * g**x % N only depends on the password.
+type SimpleSRPMITM struct {
+	*SRPParams
+	Captures []SimpleSRPCapture
+}
+
+// Serve records a single login attempt on ch and lets it in.
+func (m *SimpleSRPMITM) Serve(ch Channel) error {
+	var hello srpHello
+	if err := recvGob(ch, &hello); err != nil {
+		return err
+	}
+	if !srpPublic(hello.A) {
+		return ErrSRPAuth
+	}
+	challenge := simpleSRPChallenge{[]byte{}, m.G, big.NewInt(1)}
+	if err := sendGob(ch, challenge); err != nil {
+		return err
+	}
+	var proof srpProof
+	if err := recvGob(ch, &proof); err != nil {
+		return err
+	}
+	m.Captures = append(m.Captures, SimpleSRPCapture{hello.Email, hello.A, proof.MAC})
+	return sendGob(ch, srpResult{true})
+}
+
+// CrackSimpleSRP tries every word against a capture from SimpleSRPMITM
+// on several goroutines until one matches or ctx is done.
+func CrackSimpleSRP(ctx context.Context, params *SRPParams, capture SimpleSRPCapture, words []string, workers int) (password string, ok bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan string)
+	found := make(chan string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for word := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				x := sha256Int([]byte(word))
+				S := new(big.Int).Exp(params.G, x, params.N)
+				S.Mul(S, capture.A)
+				S.Mod(S, params.N)
+				if hmac.Equal(srpProofMAC(S, []byte{}), capture.MAC) {
+					found <- word
+					cancel()
+				}
+			}
+		}()
+	}
+feed:
+	for _, word := range words {
+		select {
+		case jobs <- word:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	select {
+	case password = <-found:
+		return password, true
+	default:
+		return "", false
+	}
+}
+
+// mustWordList returns the unique lowercase words in a text file
+// in the order they first appear.
+func mustWordList(name string) []string {
+	b, err := ioutil.ReadFile(name)
+	die(err)
+	fields := strings.FieldsFunc(strings.ToLower(string(b)), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	seen := map[string]bool{}
+	var words []string
+	for _, word := range fields {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// EGCD returns g = gcd(a, b) and x, y such that a*x + b*y = g.
+func EGCD(a, b *big.Int) (g, x, y *big.Int) {
+	if b.Sign() == 0 {
+		return new(big.Int).Set(a), big.NewInt(1), big.NewInt(0)
+	}
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	g, x1, y1 := EGCD(b, r)
+	// x = y1, y = x1 - q*y1
+	y = new(big.Int).Mul(q, y1)
+	y.Sub(x1, y)
+	return g, y1, y
+}
+
+// InvMod returns x such that a*x % m == 1, if there is one.
+func InvMod(a, m *big.Int) (*big.Int, bool) {
+	g, x, _ := EGCD(new(big.Int).Mod(a, m), m)
+	if g.Cmp(big.NewInt(1)) != 0 {
+		return nil, false
+	}
+	return x.Mod(x, m), true
+}
+
+// GeneratePrime returns a random prime with the top two bits set,
+// so the product of two of them has exactly 2*bits bits.
+func GeneratePrime(bits int) *big.Int {
+	if bits < 3 {
+		log.Fatalf("can't generate %d-bit prime", bits)
+	}
+	b := make([]byte, (bits+7)/8)
+	excess := uint(len(b)*8 - bits)
+	for {
+		_, err := rand.Read(b)
+		die(err)
+		b[0] &= 0xff >> excess
+		b[0] |= 0xc0 >> excess
+		if excess == 7 {
+			b[1] |= 0x80
+		}
+		b[len(b)-1] |= 1
+		p := new(big.Int).SetBytes(b)
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+type RSAPublicKey struct {
+	N, E *big.Int
+}
+
+// RSAPrivateKey keeps the factors and CRT values around
+// so that attacks can poke at them.
+type RSAPrivateKey struct {
+	RSAPublicKey
+	D, P, Q      *big.Int
+	Dp, Dq, Qinv *big.Int
+}
+
+// GenerateRSAKey returns a key with a bits-bit modulus and public exponent e.
+func GenerateRSAKey(bits int, e int64) *RSAPrivateKey {
+	E := big.NewInt(e)
+	one := big.NewInt(1)
+	for {
+		p := GeneratePrime(bits - bits/2)
+		q := GeneratePrime(bits / 2)
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		pm1 := new(big.Int).Sub(p, one)
+		qm1 := new(big.Int).Sub(q, one)
+		et := new(big.Int).Mul(pm1, qm1)
+		d, ok := InvMod(E, et)
+		if !ok {
+			continue
+		}
+		qinv, _ := InvMod(q, p)
+		return &RSAPrivateKey{
+			RSAPublicKey: RSAPublicKey{N: new(big.Int).Mul(p, q), E: E},
+			D:            d,
+			P:            p,
+			Q:            q,
+			Dp:           new(big.Int).Mod(d, pm1),
+			Dq:           new(big.Int).Mod(d, qm1),
+			Qinv:         qinv,
+		}
+	}
+}
+
+func (k *RSAPublicKey) EncryptInt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, k.E, k.N)
+}
+
+// Encrypt is textbook RSA with no padding.
+func (k *RSAPublicKey) Encrypt(plaintext []byte) []byte {
+	return k.EncryptInt(new(big.Int).SetBytes(plaintext)).Bytes()
+}
+
+// DecryptInt uses the CRT values to decrypt c.
+func (k *RSAPrivateKey) DecryptInt(c *big.Int) *big.Int {
+	m1 := new(big.Int).Exp(c, k.Dp, k.P)
+	m2 := new(big.Int).Exp(c, k.Dq, k.Q)
+	// m = m2 + q * (qinv * (m1 - m2) % p)
+	h := m1.Sub(m1, m2)
+	h.Mul(h, k.Qinv)
+	h.Mod(h, k.P)
+	h.Mul(h, k.Q)
+	return h.Add(h, m2)
+}
+
+// Decrypt is textbook RSA with no padding.
+// Leading zero bytes of the plaintext are lost.
+func (k *RSAPrivateKey) Decrypt(cipher []byte) []byte {
+	return k.DecryptInt(new(big.Int).SetBytes(cipher)).Bytes()
+}
+
+// CRT returns the x < M = product(moduli) with x % moduli[i] == residues[i].
+// The moduli must be pairwise coprime.
+func CRT(residues, moduli []*big.Int) (x, M *big.Int) {
+	M = big.NewInt(1)
+	for _, m := range moduli {
+		M.Mul(M, m)
+	}
+	x = new(big.Int)
+	for i, m := range moduli {
+		ms := new(big.Int).Quo(M, m)
+		inv, ok := InvMod(ms, m)
+		if !ok {
+			log.Fatalf("moduli are not coprime")
+		}
+		term := new(big.Int).Mul(residues[i], ms)
+		term.Mul(term, inv)
+		x.Add(x, term)
+	}
+	return x.Mod(x, M), M
+}
+
+// NthRoot returns the floor of the nth root of x
+// and whether it is exact.
+func NthRoot(x *big.Int, n int) (root *big.Int, exact bool) {
+	if x.Sign() < 0 || n < 1 {
+		log.Fatalf("bad root %d of %v", n, x)
+	}
+	if x.Sign() == 0 {
+		return new(big.Int), true
+	}
+	N := big.NewInt(int64(n))
+	Nm1 := big.NewInt(int64(n - 1))
+	// Newton's method, starting above the root
+	root = new(big.Int).Lsh(big.NewInt(1), uint((x.BitLen()+n-1)/n))
+	for {
+		// next = ((n-1)*root + x / root**(n-1)) / n
+		next := new(big.Int).Exp(root, Nm1, nil)
+		next.Quo(x, next)
+		next.Add(next, new(big.Int).Mul(Nm1, root))
+		next.Quo(next, N)
+		if next.Cmp(root) >= 0 {
+			break
+		}
+		root = next
+	}
+	return root, new(big.Int).Exp(root, N, nil).Cmp(x) == 0
+}
+
+// HastadBroadcast recovers a message encrypted without padding
+// under e different keys that all share the small exponent e.
+func HastadBroadcast(ciphertexts [][]byte, keys []*RSAPublicKey) ([]byte, bool) {
+	e := keys[0].E
+	if !e.IsInt64() || int64(len(keys)) != e.Int64() || len(ciphertexts) != len(keys) {
+		return nil, false
+	}
+	residues := make([]*big.Int, len(keys))
+	moduli := make([]*big.Int, len(keys))
+	for i, k := range keys {
+		if k.E.Cmp(e) != 0 {
+			return nil, false
+		}
+		residues[i] = new(big.Int).SetBytes(ciphertexts[i])
+		moduli[i] = k.N
+	}
+	// m**e < product(n) so m**e is recovered exactly
+	me, _ := CRT(residues, moduli)
+	m, exact := NthRoot(me, int(e.Int64()))
+	if !exact {
+		return nil, false
+	}
+	return m.Bytes(), true
+}
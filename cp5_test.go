@@ -0,0 +1,620 @@
+package cryptopals
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test33(t *testing.T) {
+	if !NISTGroup.P.ProbablyPrime(20) {
+		t.Fatal("NIST p is not prime")
+	}
+	tcs := []struct {
+		name  string
+		group *DHGroup
+	}{
+		{"toy", ToyGroup},
+		{"nist", NISTGroup},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a, A := tc.group.GenerateKey()
+			b, B := tc.group.GenerateKey()
+			s1 := tc.group.SharedSecret(a, B)
+			s2 := tc.group.SharedSecret(b, A)
+			if s1.Cmp(s2) != 0 {
+				t.Fatalf("secrets differ: %v != %v", s1, s2)
+			}
+			equalBytes(t, DHKey(s1), DHKey(s2))
+			if len(DHKey(s1)) != 16 {
+				t.Errorf("bad key length %d", len(DHKey(s1)))
+			}
+		})
+	}
+}
+
+func TestDHToyGroup(t *testing.T) {
+	// 5**3 % 37 = 14, 5**7 % 37 = 18, 14**7 % 37 = 18**3 % 37 = 23
+	a, b := big.NewInt(3), big.NewInt(7)
+	A, B := ToyGroup.PublicKey(a), ToyGroup.PublicKey(b)
+	if A.Int64() != 14 || B.Int64() != 18 {
+		t.Fatalf("bad public keys %v %v", A, B)
+	}
+	if s := ToyGroup.SharedSecret(a, B); s.Int64() != 23 {
+		t.Errorf("bad secret %v", s)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	a, b := Pipe()
+	if err := a.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	if err := a.Send([]byte("again")); err != io.ErrClosedPipe {
+		t.Errorf("got %v; want %v", err, io.ErrClosedPipe)
+	}
+	msg, err := b.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalString(t, string(msg), "hello")
+	if _, err = b.Recv(); err != io.EOF {
+		t.Errorf("got %v; want %v", err, io.EOF)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	relay := func(mb Middlebox) (a, b Channel, done <-chan error) {
+		a, mitmA := Pipe()
+		mitmB, b := Pipe()
+		errc := make(chan error, 1)
+		go func() { errc <- Relay(mitmA, mitmB, mb) }()
+		return a, b, errc
+	}
+	wait := func(t *testing.T, done <-chan error) {
+		t.Helper()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("relay failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("relay did not finish")
+		}
+	}
+
+	t.Run("inject", func(t *testing.T) {
+		triple := func(dir Direction, msg []byte) [][]byte {
+			return [][]byte{msg, msg, msg}
+		}
+		a, b, done := relay(triple)
+		if err := a.Send([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := b.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		equalString(t, string(msg), "hello")
+		// Hang up with injected messages still on the way
+		b.Close()
+		wait(t, done)
+		if _, err = a.Recv(); err != io.EOF {
+			t.Errorf("got %v; want %v", err, io.EOF)
+		}
+		a.Close()
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		censor := func(dir Direction, msg []byte) [][]byte {
+			if string(msg) == "drop" {
+				return nil
+			}
+			return [][]byte{msg}
+		}
+		a, b, done := relay(censor)
+		go func() {
+			for _, msg := range []string{"keep", "drop", "also keep"} {
+				a.Send([]byte(msg))
+			}
+			a.Close()
+		}()
+		var got []string
+		for {
+			msg, err := b.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, string(msg))
+		}
+		equalString(t, strings.Join(got, ","), "keep,also keep")
+		b.Close()
+		wait(t, done)
+	})
+}
+
+func runDHEcho(t *testing.T, a, b Channel, msg string) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		defer b.Close()
+		errc <- DHEchoBot(b)
+	}()
+	reply, err := DHEchoClient(a, NISTGroup, []byte(msg))
+	a.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-errc; err != nil {
+		t.Fatal(err)
+	}
+	equalString(t, string(reply), msg)
+}
+
+func tcpMITMPipe(t *testing.T, mb Middlebox) (a, b Channel) {
+	a, mitmA, err := TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mitmB, b, err := TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Relay(mitmA, mitmB, mb)
+	return a, b
+}
+
+func Test34(t *testing.T) {
+	const msg = "Ice, Ice, baby"
+	t.Run("direct", func(t *testing.T) {
+		a, b := Pipe()
+		runDHEcho(t, a, b, msg)
+	})
+	t.Run("tcp", func(t *testing.T) {
+		a, b, err := TCPPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		runDHEcho(t, a, b, msg)
+	})
+	t.Run("passthrough", func(t *testing.T) {
+		a, b := MITMPipe(Passthrough)
+		runDHEcho(t, a, b, msg)
+	})
+	t.Run("key fixing", func(t *testing.T) {
+		var k KeyFixingAttack
+		a, b := MITMPipe(k.Middlebox)
+		runDHEcho(t, a, b, msg)
+		if len(k.Plaintexts) != 2 {
+			t.Fatalf("got %d plaintexts; want 2", len(k.Plaintexts))
+		}
+		for _, p := range k.Plaintexts {
+			equalString(t, string(p), msg)
+		}
+	})
+	t.Run("key fixing over tcp", func(t *testing.T) {
+		var k KeyFixingAttack
+		a, b := tcpMITMPipe(t, k.Middlebox)
+		runDHEcho(t, a, b, msg)
+		if len(k.Plaintexts) != 2 {
+			t.Fatalf("got %d plaintexts; want 2", len(k.Plaintexts))
+		}
+		for _, p := range k.Plaintexts {
+			equalString(t, string(p), msg)
+		}
+	})
+}
+
+func Test35(t *testing.T) {
+	const msg = "Ice, Ice, baby"
+	run := func(t *testing.T, a, b Channel) {
+		errc := make(chan error, 1)
+		go func() {
+			defer b.Close()
+			errc <- DHNegotiatedEchoBot(b)
+		}()
+		reply, err := DHNegotiatedEchoClient(a, NISTGroup, []byte(msg))
+		a.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = <-errc; err != nil {
+			t.Fatal(err)
+		}
+		equalString(t, string(reply), msg)
+	}
+	t.Run("direct", func(t *testing.T) {
+		a, b := Pipe()
+		run(t, a, b)
+	})
+	tcs := []struct {
+		name string
+		g    func(p *big.Int) *big.Int
+	}{
+		{"g=1", GOne},
+		{"g=p", GP},
+		{"g=p-1", GPMinusOne},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			// Repeat to hit both parities for g = p - 1
+			for i := 0; i < 8; i++ {
+				m := MaliciousGAttack{G: tc.g}
+				a, b := MITMPipe(m.Middlebox)
+				run(t, a, b)
+				if len(m.Plaintexts) != 2 {
+					t.Fatalf("got %d plaintexts; want 2", len(m.Plaintexts))
+				}
+				for _, p := range m.Plaintexts {
+					equalString(t, string(p), msg)
+				}
+			}
+		})
+	}
+}
+
+func TestDHHostileGroup(t *testing.T) {
+	tcs := []struct{ p, g int64 }{
+		{2, 2}, {3, 2}, {0, 5}, {-37, 5}, {37, 0}, {37, -5}, {37, 38},
+	}
+	bots := map[string]func(Channel) error{
+		"echo":       DHEchoBot,
+		"negotiated": DHNegotiatedEchoBot,
+	}
+	for _, tc := range tcs {
+		p, g := big.NewInt(tc.p), big.NewInt(tc.g)
+		for name, bot := range bots {
+			t.Run(fmt.Sprintf("%s/p=%d,g=%d", name, tc.p, tc.g), func(t *testing.T) {
+				a, b := Pipe()
+				defer a.Close()
+				msg := EncodeInts(p, g)
+				if name == "echo" {
+					msg = EncodeInts(p, g, big.NewInt(1))
+				}
+				go a.Send(msg)
+				if err := bot(b); err != ErrDHGroup {
+					t.Errorf("got %v; want %v", err, ErrDHGroup)
+				}
+			})
+		}
+		t.Run(fmt.Sprintf("client/p=%d,g=%d", tc.p, tc.g), func(t *testing.T) {
+			a, b := Pipe()
+			defer b.Close()
+			go func() {
+				b.Recv()
+				b.Send(EncodeInts(p, g))
+			}()
+			if _, err := DHNegotiatedEchoClient(a, NISTGroup, []byte("hi")); err != ErrDHGroup {
+				t.Errorf("got %v; want %v", err, ErrDHGroup)
+			}
+		})
+	}
+}
+
+func TestPredictSubgroupSecret(t *testing.T) {
+	p := ToyGroup.P
+	for _, g := range []*big.Int{GOne(p), GP(p), GPMinusOne(p)} {
+		group := &DHGroup{P: p, G: g}
+		for a := int64(1); a <= 4; a++ {
+			for b := int64(1); b <= 4; b++ {
+				A := group.PublicKey(big.NewInt(a))
+				B := group.PublicKey(big.NewInt(b))
+				want := group.SharedSecret(big.NewInt(a), B)
+				if have := PredictSubgroupSecret(p, A, B); have.Cmp(want) != 0 {
+					t.Errorf("g=%v a=%d b=%d: got %v; want %v", g, a, b, have, want)
+				}
+			}
+		}
+	}
+}
+
+func runSRP(t *testing.T, s *SRPServer, a, b Channel, login func(Channel) error) (serverErr, clientErr error) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		defer b.Close()
+		errc <- s.Serve(b)
+	}()
+	clientErr = login(a)
+	a.Close()
+	return <-errc, clientErr
+}
+
+func Test36(t *testing.T) {
+	s := NewSRPServer(NISTSRP)
+	s.Register("ishmael@example.com", "whale")
+	tcs := []struct {
+		name            string
+		email, password string
+		ok              bool
+	}{
+		{"good", "ishmael@example.com", "whale", true},
+		{"wrong password", "ishmael@example.com", "squid", false},
+		{"unknown user", "ahab@example.com", "whale", false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			login := func(ch Channel) error {
+				return SRPLogin(ch, NISTSRP, tc.email, tc.password)
+			}
+			a, b := Pipe()
+			serverErr, clientErr := runSRP(t, s, a, b, login)
+			for _, err := range []error{serverErr, clientErr} {
+				if tc.ok && err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if !tc.ok && err != ErrSRPAuth {
+					t.Errorf("got %v; want %v", err, ErrSRPAuth)
+				}
+			}
+		})
+	}
+	t.Run("tcp", func(t *testing.T) {
+		a, b, err := TCPPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		login := func(ch Channel) error {
+			return SRPLogin(ch, NISTSRP, "ishmael@example.com", "whale")
+		}
+		serverErr, clientErr := runSRP(t, s, a, b, login)
+		if serverErr != nil || clientErr != nil {
+			t.Errorf("unexpected errors: %v, %v", serverErr, clientErr)
+		}
+	})
+}
+
+func Test37(t *testing.T) {
+	N := NISTSRP.N
+	for _, validate := range []bool{false, true} {
+		s := NewSRPServer(NISTSRP)
+		s.Validate = validate
+		s.Register("ishmael@example.com", "whale")
+		for _, mult := range []int64{0, 1, 2, 3} {
+			A := new(big.Int).Mul(N, big.NewInt(mult))
+			name := fmt.Sprintf("validate=%v/A=%dN", validate, mult)
+			t.Run(name, func(t *testing.T) {
+				login := func(ch Channel) error {
+					return SRPZeroKeyLogin(ch, "ishmael@example.com", A)
+				}
+				a, b := Pipe()
+				serverErr, clientErr := runSRP(t, s, a, b, login)
+				for _, err := range []error{serverErr, clientErr} {
+					if !validate && err != nil {
+						t.Errorf("bypass failed: %v", err)
+					}
+					if validate && err != ErrSRPAuth {
+						t.Errorf("got %v; want %v", err, ErrSRPAuth)
+					}
+				}
+			})
+		}
+		t.Run(fmt.Sprintf("validate=%v/password", validate), func(t *testing.T) {
+			login := func(ch Channel) error {
+				return SRPLogin(ch, NISTSRP, "ishmael@example.com", "whale")
+			}
+			a, b := Pipe()
+			serverErr, clientErr := runSRP(t, s, a, b, login)
+			if serverErr != nil || clientErr != nil {
+				t.Errorf("unexpected errors: %v, %v", serverErr, clientErr)
+			}
+		})
+	}
+}
+
+func TestSRPParams(t *testing.T) {
+	N := NISTSRP.N.Bytes()
+	padded := make([]byte, len(N))
+	padded[len(padded)-1] = 2
+	k := sha256.Sum256(append(append([]byte{}, N...), padded...))
+	equalBytes(t, NISTSRP.K.Bytes(), k[:])
+}
+
+func TestSRPMalformed(t *testing.T) {
+	validating := NewSRPServer(NISTSRP)
+	validating.Validate = true
+	servers := map[string]interface{ Serve(Channel) error }{
+		"srp":         NewSRPServer(NISTSRP),
+		"validate":    validating,
+		"simple":      NewSimpleSRPServer(NISTSRP),
+		"simple mitm": &SimpleSRPMITM{SRPParams: NISTSRP},
+	}
+	for name, s := range servers {
+		for _, A := range []*big.Int{nil, big.NewInt(-2)} {
+			t.Run(fmt.Sprintf("%s/A=%v", name, A), func(t *testing.T) {
+				a, b := Pipe()
+				defer a.Close()
+				go sendGob(a, srpHello{"a@b", A})
+				if err := s.Serve(b); err != ErrSRPAuth {
+					t.Errorf("got %v; want %v", err, ErrSRPAuth)
+				}
+			})
+		}
+	}
+
+	clients := []struct {
+		name      string
+		challenge interface{}
+		login     func(Channel, *SRPParams, string, string) error
+	}{
+		{"srp", srpChallenge{[]byte{}, nil}, SRPLogin},
+		{"srp B=N", srpChallenge{[]byte{}, NISTSRP.N}, SRPLogin},
+		{"simple", simpleSRPChallenge{[]byte{}, nil, big.NewInt(1)}, SimpleSRPLogin},
+		{"simple u", simpleSRPChallenge{[]byte{}, NISTSRP.G, nil}, SimpleSRPLogin},
+	}
+	for _, tc := range clients {
+		t.Run("client "+tc.name, func(t *testing.T) {
+			a, b := Pipe()
+			defer b.Close()
+			go func() {
+				var hello srpHello
+				recvGob(b, &hello)
+				sendGob(b, tc.challenge)
+			}()
+			if err := tc.login(a, NISTSRP, "a@b", "pw"); err != ErrSRPAuth {
+				t.Errorf("got %v; want %v", err, ErrSRPAuth)
+			}
+		})
+	}
+}
+
+func Test38(t *testing.T) {
+	words := mustWordList("moby-dick.txt")
+	password := words[len(words)/10]
+	login := func(ch Channel) error {
+		return SimpleSRPLogin(ch, NISTSRP, "ishmael@example.com", password)
+	}
+
+	t.Run("honest", func(t *testing.T) {
+		s := NewSimpleSRPServer(NISTSRP)
+		s.Register("ishmael@example.com", password)
+		errc := make(chan error, 1)
+		a, b := Pipe()
+		go func() {
+			defer b.Close()
+			errc <- s.Serve(b)
+		}()
+		if err := login(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("mitm", func(t *testing.T) {
+		m := SimpleSRPMITM{SRPParams: NISTSRP}
+		errc := make(chan error, 1)
+		a, b := Pipe()
+		go func() {
+			defer b.Close()
+			errc <- m.Serve(b)
+		}()
+		if err := login(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Captures) != 1 {
+			t.Fatalf("got %d captures; want 1", len(m.Captures))
+		}
+
+		cracked, ok := CrackSimpleSRP(context.Background(), NISTSRP, m.Captures[0], words, 4)
+		if !ok {
+			t.Fatal("password not found")
+		}
+		equalString(t, cracked, password)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, ok = CrackSimpleSRP(ctx, NISTSRP, m.Captures[0], words, 4); ok {
+			t.Error("canceled crack found password")
+		}
+	})
+}
+
+func TestInvMod(t *testing.T) {
+	tcs := []struct {
+		a, m, inverse int64
+		ok            bool
+	}{
+		{17, 3120, 2753, true},
+		{3, 7, 5, true},
+		{-3, 7, 2, true},
+		{4, 8, 0, false},
+	}
+	for _, tc := range tcs {
+		inverse, ok := InvMod(big.NewInt(tc.a), big.NewInt(tc.m))
+		if ok != tc.ok || ok && inverse.Int64() != tc.inverse {
+			t.Errorf("InvMod(%d, %d) = %v, %v; want %d, %v", tc.a, tc.m, inverse, ok, tc.inverse, tc.ok)
+		}
+	}
+	g, x, y := EGCD(big.NewInt(240), big.NewInt(46))
+	if g.Int64() != 2 || 240*x.Int64()+46*y.Int64() != 2 {
+		t.Errorf("bad EGCD: %v, %v, %v", g, x, y)
+	}
+}
+
+func Test39(t *testing.T) {
+	for _, e := range []int64{3, 65537} {
+		for _, bits := range []int{256, 1024} {
+			t.Run(fmt.Sprintf("e=%d/bits=%d", e, bits), func(t *testing.T) {
+				k := GenerateRSAKey(bits, e)
+				if k.N.BitLen() != bits {
+					t.Errorf("bad modulus size %d", k.N.BitLen())
+				}
+				msg := "Ice, Ice, baby"
+				cipher := k.Encrypt([]byte(msg))
+				equalString(t, string(k.Decrypt(cipher)), msg)
+
+				// CRT agrees with plain m**d % n
+				c := new(big.Int).SetBytes(cipher)
+				m := new(big.Int).Exp(c, k.D, k.N)
+				if m.Cmp(k.DecryptInt(c)) != 0 {
+					t.Error("CRT decryption disagrees")
+				}
+			})
+		}
+	}
+}
+
+func TestCRT(t *testing.T) {
+	x, M := CRT(
+		[]*big.Int{big.NewInt(2), big.NewInt(3), big.NewInt(2)},
+		[]*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(7)},
+	)
+	if x.Int64() != 23 || M.Int64() != 105 {
+		t.Errorf("got %v, %v; want 23, 105", x, M)
+	}
+}
+
+func TestNthRoot(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 17} {
+		for i := 0; i < 20; i++ {
+			r := new(big.Int).SetBytes(randomBytes(i * 3))
+			x := new(big.Int).Exp(r, big.NewInt(int64(n)), nil)
+			root, exact := NthRoot(x, n)
+			if !exact || root.Cmp(r) != 0 {
+				t.Errorf("NthRoot(%v, %d) = %v, %v", x, n, root, exact)
+			}
+			if r.Sign() == 0 {
+				continue
+			}
+			x.Add(x, big.NewInt(1))
+			// One more is not a perfect power but has the same floor
+			if root, exact = NthRoot(x, n); exact || root.Cmp(r) != 0 {
+				t.Errorf("NthRoot(%v, %d) = %v, %v", x, n, root, exact)
+			}
+		}
+	}
+}
+
+func Test40(t *testing.T) {
+	msg := "Ice, Ice, baby. Vanilla's on the mike, man I'm not lazy."
+	for _, e := range []int64{3, 5, 7} {
+		t.Run(fmt.Sprint(e), func(t *testing.T) {
+			keys := make([]*RSAPublicKey, e)
+			ciphertexts := make([][]byte, e)
+			for i := range keys {
+				keys[i] = &GenerateRSAKey(512, e).RSAPublicKey
+				ciphertexts[i] = keys[i].Encrypt([]byte(msg))
+			}
+			plaintext, ok := HastadBroadcast(ciphertexts, keys)
+			if !ok {
+				t.Fatal("attack failed")
+			}
+			equalString(t, string(plaintext), msg)
+		})
+	}
+}
@@ -0,0 +1,754 @@
+package cryptopals
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+var ErrRSAReplay = errors.New("rsa: ciphertext already decrypted")
+
+// RSAOracle decrypts any ciphertext, but only once.
+type RSAOracle struct {
+	key  *RSAPrivateKey
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]bool
+}
+
+func NewRSAOracle(key *RSAPrivateKey) *RSAOracle {
+	return &RSAOracle{key: key, seen: map[[sha256.Size]byte]bool{}}
+}
+
+func (o *RSAOracle) Decrypt(cipher []byte) ([]byte, error) {
+	// Hash the number mod N, so neither leading zeros
+	// nor adding N counts as a new ciphertext
+	c := new(big.Int).SetBytes(cipher)
+	c.Mod(c, o.key.N)
+	h := sha256.Sum256(c.Bytes())
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.seen[h] {
+		return nil, ErrRSAReplay
+	}
+	o.seen[h] = true
+	return o.key.DecryptInt(c).Bytes(), nil
+}
+
+// RSAUnpaddedRecovery gets an oracle to decrypt a ciphertext
+// it has already seen by blinding it as c * s**e, which decrypts
+// to m * s, and then dividing out s.
+func RSAUnpaddedRecovery(pub *RSAPublicKey, cipher []byte, decrypt func([]byte) ([]byte, error)) ([]byte, error) {
+	var s *big.Int
+	for {
+		var err error
+		s, err = rand.Int(rand.Reader, pub.N)
+		die(err)
+		if s.Cmp(big.NewInt(1)) > 0 {
+			break
+		}
+	}
+	blinded := pub.EncryptInt(s)
+	blinded.Mul(blinded, new(big.Int).SetBytes(cipher))
+	blinded.Mod(blinded, pub.N)
+
+	plain, err := decrypt(blinded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	sinv, ok := InvMod(s, pub.N)
+	if !ok {
+		// s shares a factor with n, which is its own kind of win
+		return nil, errors.New("blinding factor is not invertible")
+	}
+	m := new(big.Int).SetBytes(plain)
+	m.Mul(m, sinv)
+	return m.Mod(m, pub.N).Bytes(), nil
+}
+
+// sha1DigestInfo is the DER prefix of a PKCS#1 v1.5 SHA-1 DigestInfo.
+var sha1DigestInfo = []byte{0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14}
+
+func (k *RSAPublicKey) size() int {
+	return (k.N.BitLen() + 7) / 8
+}
+
+// leftPad returns b preceded by enough zeros to make it size bytes.
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	r := make([]byte, size)
+	copy(r[size-len(b):], b)
+	return r
+}
+
+// pkcs1v15SignatureBlock returns 00 01 FF ... FF 00 DigestInfo SHA1(msg).
+func pkcs1v15SignatureBlock(msg []byte, size int) []byte {
+	t := append(append([]byte{}, sha1DigestInfo...), SHA1Sum(msg)...)
+	if size < len(t)+11 {
+		log.Fatalf("key too short for PKCS#1 v1.5: %d bytes", size)
+	}
+	em := make([]byte, size)
+	em[1] = 0x01
+	for i := 2; i < size-len(t)-1; i++ {
+		em[i] = 0xff
+	}
+	copy(em[size-len(t):], t)
+	return em
+}
+
+// SignPKCS1v15 signs the SHA-1 hash of msg.
+func SignPKCS1v15(key *RSAPrivateKey, msg []byte) []byte {
+	em := pkcs1v15SignatureBlock(msg, key.size())
+	s := key.DecryptInt(new(big.Int).SetBytes(em))
+	return leftPad(s.Bytes(), key.size())
+}
+
+// VerifyPKCS1v15 checks that sig opens to exactly the expected block.
+func VerifyPKCS1v15(pub *RSAPublicKey, msg, sig []byte) bool {
+	em := leftPad(pub.EncryptInt(new(big.Int).SetBytes(sig)).Bytes(), pub.size())
+	return subtle.ConstantTimeCompare(em, pkcs1v15SignatureBlock(msg, pub.size())) == 1
+}
+
+// VerifyPKCS1v15Sloppy parses the block left to right like a careless
+// implementation and never checks that the hash is right-justified,
+// so anything may follow it.
+func VerifyPKCS1v15Sloppy(pub *RSAPublicKey, msg, sig []byte) bool {
+	em := leftPad(pub.EncryptInt(new(big.Int).SetBytes(sig)).Bytes(), pub.size())
+	if em[0] != 0x00 || em[1] != 0x01 {
+		return false
+	}
+	i := 2
+	for i < len(em) && em[i] == 0xff {
+		i++
+	}
+	if i == 2 || i == len(em) || em[i] != 0x00 {
+		return false
+	}
+	rest := em[i+1:]
+	if !bytes.HasPrefix(rest, sha1DigestInfo) {
+		return false
+	}
+	rest = rest[len(sha1DigestInfo):]
+	if len(rest) < SHA1Size {
+		return false
+	}
+	return bytes.Equal(rest[:SHA1Size], SHA1Sum(msg))
+}
+
+// ForgePKCS1v15 makes a signature for msg that VerifyPKCS1v15Sloppy
+// accepts under an e = 3 key without the private key.
+// It builds 00 01 FF 00 DigestInfo hash followed by garbage
+// and takes the cube root, rounding up. The error from rounding
+// only reaches the garbage as long as the key is big enough.
+func ForgePKCS1v15(pub *RSAPublicKey, msg []byte) []byte {
+	if pub.E.Cmp(big.NewInt(3)) != 0 {
+		log.Fatalf("can't forge for e = %v", pub.E)
+	}
+	size := pub.size()
+	block := make([]byte, size)
+	n := copy(block, []byte{0x00, 0x01, 0xff, 0x00})
+	n += copy(block[n:], sha1DigestInfo)
+	copy(block[n:], SHA1Sum(msg))
+	lo := new(big.Int).SetBytes(block)
+
+	root, exact := NthRoot(lo, 3)
+	if !exact {
+		root.Add(root, big.NewInt(1))
+	}
+	return leftPad(root.Bytes(), size)
+}
+
+// DSAParams are DSA domain parameters.
+type DSAParams struct {
+	P, Q, G *big.Int
+}
+
+var CryptopalsDSA = &DSAParams{
+	P: mustBigHex("800000000000000089e1855218a0e7dac38136ffafa72eda7859f2171e25e65eac698c1702578b07dc2a1076da241c76c62d374d8389ea5aeffd3226a0530cc565f3bf6b50929139ebeac04f48c3c84afb796d61e5a4f9a8fda812ab59494232c7d2b4deb50aa18ee9e132bfa85ac4374d7f9091abc3d015efc871a584471bb1"),
+	Q: mustBigHex("f4f47f05794b256174bba6e9b396a7707e563c5b"),
+	G: mustBigHex("5958c9d3898b224b12672c0b98e06c60df923cb8bc999d119458fef538b8fa4046c8db53039db620c094c9fa077ef389b5322a559946a71903f990f1f7e0e025e2d7f7cf494aff1a0470f5b64c36b625a097f1651fe775323556fe00b3608c887892878480e99041be601a62166ca6894bdd41a7054ec89f756ba9fc95302291"),
+}
+
+type DSAPublicKey struct {
+	*DSAParams
+	Y *big.Int
+}
+
+type DSAPrivateKey struct {
+	DSAPublicKey
+	X *big.Int
+}
+
+type DSASignature struct {
+	R, S *big.Int
+}
+
+// DSAHash is SHA-1 of msg as a number.
+func DSAHash(msg []byte) *big.Int {
+	return new(big.Int).SetBytes(SHA1Sum(msg))
+}
+
+// randomBelow returns a random number in [1, n).
+func randomBelow(n *big.Int) *big.Int {
+	for {
+		k, err := rand.Int(rand.Reader, n)
+		die(err)
+		if k.Sign() > 0 {
+			return k
+		}
+	}
+}
+
+func (params *DSAParams) GenerateKey() *DSAPrivateKey {
+	x := randomBelow(params.Q)
+	y := new(big.Int).Exp(params.G, x, params.P)
+	return &DSAPrivateKey{DSAPublicKey{params, y}, x}
+}
+
+// SignWithK signs the hash h with nonce k.
+// It fails if r or s comes out to 0.
+func (key *DSAPrivateKey) SignWithK(h, k *big.Int) (DSASignature, bool) {
+	// r = (g**k % p) % q
+	r := new(big.Int).Exp(key.G, k, key.P)
+	r.Mod(r, key.Q)
+	kinv, ok := InvMod(k, key.Q)
+	if !ok {
+		return DSASignature{}, false
+	}
+	// s = k**-1 * (h + x*r) % q
+	s := new(big.Int).Mul(key.X, r)
+	s.Add(s, h)
+	s.Mul(s, kinv)
+	s.Mod(s, key.Q)
+	if r.Sign() == 0 || s.Sign() == 0 {
+		return DSASignature{}, false
+	}
+	return DSASignature{r, s}, true
+}
+
+// Sign signs msg with a random nonce. Honest parameters almost never
+// need a second nonce, but tampered ones like g = 0 make every r zero,
+// so it gives up after a few tries.
+func (key *DSAPrivateKey) Sign(msg []byte) (DSASignature, bool) {
+	h := DSAHash(msg)
+	for tries := 0; tries < 8; tries++ {
+		if sig, ok := key.SignWithK(h, randomBelow(key.Q)); ok {
+			return sig, true
+		}
+	}
+	return DSASignature{}, false
+}
+
+// Verify checks sig against key, including sanity checks
+// on the domain parameters and the ranges of r and s.
+func (key *DSAPublicKey) Verify(msg []byte, sig DSASignature) bool {
+	return key.verify(msg, sig, true)
+}
+
+// VerifyLax is Verify without any of the sanity checks,
+// so it trusts whatever parameters it is handed.
+func (key *DSAPublicKey) VerifyLax(msg []byte, sig DSASignature) bool {
+	return key.verify(msg, sig, false)
+}
+
+func (key *DSAPublicKey) verify(msg []byte, sig DSASignature, strict bool) bool {
+	if strict && !key.validParams() {
+		return false
+	}
+	if strict && (sig.R.Sign() <= 0 || sig.R.Cmp(key.Q) >= 0 ||
+		sig.S.Sign() <= 0 || sig.S.Cmp(key.Q) >= 0) {
+		return false
+	}
+	w, ok := InvMod(sig.S, key.Q)
+	if !ok {
+		return false
+	}
+	// v = (g**(h*w) * y**(r*w) % p) % q
+	u1 := new(big.Int).Mul(DSAHash(msg), w)
+	u1.Mod(u1, key.Q)
+	u2 := new(big.Int).Mul(sig.R, w)
+	u2.Mod(u2, key.Q)
+	v := new(big.Int).Exp(key.G, u1, key.P)
+	v.Mul(v, new(big.Int).Exp(key.Y, u2, key.P))
+	v.Mod(v, key.P)
+	v.Mod(v, key.Q)
+	return v.Cmp(sig.R) == 0
+}
+
+// validParams checks that 1 < g < p and g has order q.
+func (params *DSAParams) validParams() bool {
+	one := big.NewInt(1)
+	if params.G.Cmp(one) <= 0 || params.G.Cmp(params.P) >= 0 {
+		return false
+	}
+	return new(big.Int).Exp(params.G, params.Q, params.P).Cmp(one) == 0
+}
+
+// DSAKeyFromNonce returns x = (s*k - h) / r % q.
+func DSAKeyFromNonce(params *DSAParams, h *big.Int, sig DSASignature, k *big.Int) *big.Int {
+	rinv, ok := InvMod(sig.R, params.Q)
+	if !ok {
+		return nil
+	}
+	x := new(big.Int).Mul(sig.S, k)
+	x.Sub(x, h)
+	x.Mul(x, rinv)
+	return x.Mod(x, params.Q)
+}
+
+// BruteForceDSAKey tries every nonce k in [0, maxK)
+// until the private key it implies matches pub.
+// Since x = s/r * k - h/r, g**x steps by g**(s/r) as k goes up,
+// so each guess costs a single multiplication.
+func BruteForceDSAKey(pub *DSAPublicKey, h *big.Int, sig DSASignature, maxK int64) (x, k *big.Int, ok bool) {
+	rinv, ok := InvMod(sig.R, pub.Q)
+	if !ok {
+		return nil, nil, false
+	}
+	step := new(big.Int).Mul(sig.S, rinv)
+	step.Mod(step, pub.Q)
+	step.Exp(pub.G, step, pub.P)
+	// g**x for k = 0 is g**(-h/r)
+	start := new(big.Int).Mul(h, rinv)
+	start.Neg(start)
+	start.Mod(start, pub.Q)
+	gx := new(big.Int).Exp(pub.G, start, pub.P)
+	for i := int64(0); i < maxK; i++ {
+		if gx.Cmp(pub.Y) == 0 {
+			k = big.NewInt(i)
+			return DSAKeyFromNonce(pub.DSAParams, h, sig, k), k, true
+		}
+		gx.Mul(gx, step)
+		gx.Mod(gx, pub.P)
+	}
+	return nil, nil, false
+}
+
+// DSAKeyFingerprint is the SHA-1 of x in hex, as cryptopals writes it.
+func DSAKeyFingerprint(x *big.Int) string {
+	return hex.EncodeToString(SHA1Sum([]byte(x.Text(16))))
+}
+
+// DSASignedMessage is one record of a 44.txt style file.
+type DSASignedMessage struct {
+	Msg []byte
+	H   *big.Int
+	Sig DSASignature
+}
+
+func mustDecimal(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		log.Fatalf("bad decimal number %q", s)
+	}
+	return n
+}
+
+// mustDSASignatureFile loads records with msg, s, r, and m (hex SHA-1) lines.
+func mustDSASignatureFile(name string) []DSASignedMessage {
+	var sigs []DSASignedMessage
+	for _, record := range mustRecordFile(name) {
+		sigs = append(sigs, DSASignedMessage{
+			Msg: []byte(record["msg"]),
+			H:   mustBigHex(record["m"]),
+			Sig: DSASignature{R: mustDecimal(record["r"]), S: mustDecimal(record["s"])},
+		})
+	}
+	return sigs
+}
+
+// RecoverRepeatedNonce looks for signatures that share r,
+// and thus k, and uses them to find the private key for pub.
+// For a pair, k = (h1 - h2) / (s1 - s2) % q.
+func RecoverRepeatedNonce(pub *DSAPublicKey, sigs []DSASignedMessage) (x, k *big.Int, ok bool) {
+	byR := map[string][]DSASignedMessage{}
+	for _, sig := range sigs {
+		r := sig.Sig.R.String()
+		byR[r] = append(byR[r], sig)
+	}
+	for _, group := range byR {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				a, b := group[i], group[j]
+				ds := new(big.Int).Sub(a.Sig.S, b.Sig.S)
+				dsinv, ok := InvMod(ds.Mod(ds, pub.Q), pub.Q)
+				if !ok {
+					continue
+				}
+				k = new(big.Int).Sub(a.H, b.H)
+				k.Mul(k, dsinv)
+				k.Mod(k, pub.Q)
+				x = DSAKeyFromNonce(pub.DSAParams, a.H, a.Sig, k)
+				if x != nil && new(big.Int).Exp(pub.G, x, pub.P).Cmp(pub.Y) == 0 {
+					return x, k, true
+				}
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// MagicDSASignature forges a signature that VerifyLax accepts
+// for every message when pub has been tampered to use g = p + 1.
+// With r = (y**z % p) % q and s = r / z % q,
+// g**u1 is 1 and y**u2 = y**(r/s) = y**z, so v = r.
+func MagicDSASignature(pub *DSAPublicKey, z *big.Int) DSASignature {
+	r := new(big.Int).Exp(pub.Y, z, pub.P)
+	r.Mod(r, pub.Q)
+	zinv, ok := InvMod(z, pub.Q)
+	if !ok {
+		log.Fatalf("z = %v is not invertible", z)
+	}
+	s := new(big.Int).Mul(r, zinv)
+	s.Mod(s, pub.Q)
+	return DSASignature{r, s}
+}
+
+// RSAParityOracle reveals only whether a ciphertext decrypts to an even number.
+type RSAParityOracle struct {
+	key   *RSAPrivateKey
+	Calls int
+}
+
+func NewRSAParityOracle(key *RSAPrivateKey) *RSAParityOracle {
+	return &RSAParityOracle{key: key}
+}
+
+func (o *RSAParityOracle) IsEven(c *big.Int) bool {
+	o.Calls++
+	return o.key.DecryptInt(c).Bit(0) == 0
+}
+
+// RSAParityAttack decrypts c by repeatedly doubling the plaintext.
+// 2m % n is even if 2m didn't wrap around n, so each answer from isEven
+// halves the range m is known to be in. After k steps m is in
+// [n*a/2**k, n*(a+1)/2**k) for an integer a, which keeps the bounds exact.
+// The upper bound is passed to progress after each step.
+func RSAParityAttack(pub *RSAPublicKey, c *big.Int, isEven func(*big.Int) bool, progress func(upper string)) []byte {
+	double := pub.EncryptInt(big.NewInt(2))
+	c = new(big.Int).Set(c)
+	a := new(big.Int)
+	upper := new(big.Int)
+	k := uint(pub.N.BitLen())
+	for i := uint(1); i <= k; i++ {
+		c.Mul(c, double)
+		c.Mod(c, pub.N)
+		a.Lsh(a, 1)
+		if !isEven(c) {
+			a.Add(a, big.NewInt(1))
+		}
+		if progress != nil {
+			// upper = n*(a+1) / 2**i
+			upper.Add(a, big.NewInt(1))
+			upper.Mul(upper, pub.N)
+			upper.Rsh(upper, i)
+			progress(string(upper.Bytes()))
+		}
+	}
+	// With the range narrower than 1, m = ceil(n*a / 2**k)
+	m := new(big.Int).Mul(a, pub.N)
+	m.Add(m, new(big.Int).Lsh(big.NewInt(1), k))
+	m.Sub(m, big.NewInt(1))
+	m.Rsh(m, k)
+	return m.Bytes()
+}
+
+// PKCS1v15Pad returns 00 02 PS 00 msg, size bytes long,
+// where PS is at least 8 random nonzero bytes.
+func PKCS1v15Pad(msg []byte, size int) []byte {
+	if len(msg) > size-11 {
+		log.Fatalf("message too long for PKCS#1 v1.5: %d > %d", len(msg), size-11)
+	}
+	em := make([]byte, size)
+	em[1] = 0x02
+	ps := em[2 : size-len(msg)-1]
+	for i := range ps {
+		for ps[i] == 0 {
+			ps[i] = randomBytes(1)[0]
+		}
+	}
+	copy(em[size-len(msg):], msg)
+	return em
+}
+
+// PKCS1v15Unpad undoes PKCS1v15Pad.
+func PKCS1v15Unpad(em []byte) ([]byte, bool) {
+	if len(em) < 11 || em[0] != 0x00 || em[1] != 0x02 {
+		return nil, false
+	}
+	i := bytes.IndexByte(em[2:], 0x00)
+	if i < 8 {
+		return nil, false
+	}
+	return em[2+i+1:], true
+}
+
+// EncryptPKCS1v15 pads msg and encrypts it.
+func (k *RSAPublicKey) EncryptPKCS1v15(msg []byte) []byte {
+	c := k.EncryptInt(new(big.Int).SetBytes(PKCS1v15Pad(msg, k.size())))
+	return leftPad(c.Bytes(), k.size())
+}
+
+// PKCS1v15Oracle only says whether a ciphertext decrypts to 00 02 ...
+type PKCS1v15Oracle struct {
+	key   *RSAPrivateKey
+	Calls int
+}
+
+func NewPKCS1v15Oracle(key *RSAPrivateKey) *PKCS1v15Oracle {
+	return &PKCS1v15Oracle{key: key}
+}
+
+func (o *PKCS1v15Oracle) Conforming(c *big.Int) bool {
+	o.Calls++
+	em := leftPad(o.key.DecryptInt(c).Bytes(), o.key.size())
+	return em[0] == 0x00 && em[1] == 0x02
+}
+
+// floorDiv and ceilDiv round x / y for y > 0.
+func floorDiv(x, y *big.Int) *big.Int {
+	return new(big.Int).Div(x, y)
+}
+
+func ceilDiv(x, y *big.Int) *big.Int {
+	r := new(big.Int).Neg(x)
+	r.Div(r, y)
+	return r.Neg(r)
+}
+
+type interval struct {
+	lo, hi *big.Int
+}
+
+// mergeIntervals sorts and joins overlapping intervals.
+func mergeIntervals(ivs []interval) []interval {
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].lo.Cmp(ivs[j].lo) < 0 })
+	var merged []interval
+	for _, iv := range ivs {
+		if n := len(merged); n > 0 && iv.lo.Cmp(merged[n-1].hi) <= 0 {
+			if iv.hi.Cmp(merged[n-1].hi) > 0 {
+				merged[n-1].hi = iv.hi
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Bleichenbacher98 decrypts c, which must be PKCS#1 v1.5 conforming,
+// using only an oracle that reports whether a ciphertext is conforming.
+// It returns the whole padded block.
+func Bleichenbacher98(pub *RSAPublicKey, c *big.Int, conforming func(*big.Int) bool) []byte {
+	var (
+		one  = big.NewInt(1)
+		k    = pub.size()
+		n    = pub.N
+		B    = new(big.Int).Lsh(one, uint(8*(k-2)))
+		B2   = new(big.Int).Lsh(B, 1)
+		B3   = new(big.Int).Mul(B, big.NewInt(3))
+		B3m1 = new(big.Int).Sub(B3, one)
+	)
+	// try reports whether c * s**e is conforming
+	try := func(s *big.Int) bool {
+		cs := pub.EncryptInt(s)
+		cs.Mul(cs, c)
+		cs.Mod(cs, n)
+		return conforming(cs)
+	}
+
+	// Step 1: c is already conforming, so s0 = 1
+	M := []interval{{B2, B3m1}}
+	var s *big.Int
+	for i := 1; ; i++ {
+		switch {
+		case i == 1:
+			// Step 2a: smallest s >= n/3B that conforms
+			s = ceilDiv(n, B3)
+			for !try(s) {
+				s.Add(s, one)
+			}
+		case len(M) > 1:
+			// Step 2b: keep counting up
+			s = new(big.Int).Add(s, one)
+			for !try(s) {
+				s.Add(s, one)
+			}
+		default:
+			// Step 2c: one interval left, so search r and s together
+			a, b := M[0].lo, M[0].hi
+			r := new(big.Int).Mul(b, s)
+			r.Sub(r, B2)
+			r.Lsh(r, 1)
+			r = ceilDiv(r, n)
+			for found := false; !found; r.Add(r, one) {
+				rn := new(big.Int).Mul(r, n)
+				lo := ceilDiv(new(big.Int).Add(B2, rn), b)
+				hi := floorDiv(new(big.Int).Add(B3m1, rn), a)
+				for s = lo; s.Cmp(hi) <= 0; s.Add(s, one) {
+					if try(s) {
+						found = true
+						break
+					}
+				}
+			}
+		}
+
+		// Step 3: narrow M using s
+		var next []interval
+		for _, iv := range M {
+			a, b := iv.lo, iv.hi
+			rlo := new(big.Int).Mul(a, s)
+			rlo.Sub(rlo, B3m1)
+			rlo = ceilDiv(rlo, n)
+			rhi := new(big.Int).Mul(b, s)
+			rhi.Sub(rhi, B2)
+			rhi = floorDiv(rhi, n)
+			for r := rlo; r.Cmp(rhi) <= 0; r = new(big.Int).Add(r, one) {
+				rn := new(big.Int).Mul(r, n)
+				lo := ceilDiv(new(big.Int).Add(B2, rn), s)
+				hi := floorDiv(new(big.Int).Add(B3m1, rn), s)
+				if lo.Cmp(a) < 0 {
+					lo = a
+				}
+				if hi.Cmp(b) > 0 {
+					hi = b
+				}
+				if lo.Cmp(hi) <= 0 {
+					next = append(next, interval{lo, hi})
+				}
+			}
+		}
+		M = mergeIntervals(next)
+
+		// Step 4: done when one value is left
+		if len(M) == 1 && M[0].lo.Cmp(M[0].hi) == 0 {
+			return leftPad(M[0].lo.Bytes(), k)
+		}
+		if len(M) == 0 {
+			log.Fatal("no intervals left")
+		}
+	}
+}
+
+var (
+	ErrOAEPFirstByte = errors.New("oaep: integer too large")
+	ErrOAEPDecoding  = errors.New("oaep: decoding error")
+)
+
+// mgf1SHA1 is MGF1 from PKCS #1 with SHA-1.
+func mgf1SHA1(seed []byte, length int) []byte {
+	var mask []byte
+	counter := make([]byte, 4)
+	for i := uint32(0); len(mask) < length; i++ {
+		binary.BigEndian.PutUint32(counter, i)
+		mask = append(mask, SHA1Sum(append(append([]byte{}, seed...), counter...))...)
+	}
+	return mask[:length]
+}
+
+// EncryptOAEP is RSAES-OAEP with SHA-1 and MGF1.
+func (k *RSAPublicKey) EncryptOAEP(msg, label []byte) []byte {
+	size := k.size()
+	if len(msg) > size-2*SHA1Size-2 {
+		log.Fatalf("message too long for OAEP: %d", len(msg))
+	}
+	// DB = lHash || PS || 01 || M
+	db := make([]byte, size-SHA1Size-1)
+	copy(db, SHA1Sum(label))
+	db[len(db)-len(msg)-1] = 0x01
+	copy(db[len(db)-len(msg):], msg)
+
+	seed := randomBytes(SHA1Size)
+	maskedDB := XorFixed(db, mgf1SHA1(seed, len(db)))
+	maskedSeed := XorFixed(seed, mgf1SHA1(maskedDB, SHA1Size))
+	em := append(append([]byte{0}, maskedSeed...), maskedDB...)
+	return leftPad(k.EncryptInt(new(big.Int).SetBytes(em)).Bytes(), size)
+}
+
+// DecryptOAEP undoes EncryptOAEP. It leaks through its errors
+// whether the decrypted integer was below 2**(8*(k-1)).
+func (k *RSAPrivateKey) DecryptOAEP(cipher, label []byte) ([]byte, error) {
+	size := k.size()
+	em := leftPad(k.DecryptInt(new(big.Int).SetBytes(cipher)).Bytes(), size)
+	if em[0] != 0x00 {
+		return nil, ErrOAEPFirstByte
+	}
+	maskedSeed, maskedDB := em[1:1+SHA1Size], em[1+SHA1Size:]
+	seed := XorFixed(maskedSeed, mgf1SHA1(maskedDB, SHA1Size))
+	db := XorFixed(maskedDB, mgf1SHA1(seed, len(maskedDB)))
+	if subtle.ConstantTimeCompare(db[:SHA1Size], SHA1Sum(label)) != 1 {
+		return nil, ErrOAEPDecoding
+	}
+	rest := db[SHA1Size:]
+	i := bytes.IndexByte(rest, 0x01)
+	if i < 0 || len(bytes.Trim(rest[:i], "\x00")) != 0 {
+		return nil, ErrOAEPDecoding
+	}
+	return rest[i+1:], nil
+}
+
+// Manger decrypts c using an oracle that says whether
+// a ciphertext decrypts to less than B = 2**(8*(k-1)).
+// It needs 2B < n and returns the whole encoded block.
+func Manger(pub *RSAPublicKey, c *big.Int, belowB func(*big.Int) bool) []byte {
+	var (
+		one = big.NewInt(1)
+		k   = pub.size()
+		n   = pub.N
+		B   = new(big.Int).Lsh(one, uint(8*(k-1)))
+		B2  = new(big.Int).Lsh(B, 1)
+	)
+	if B2.Cmp(n) >= 0 {
+		log.Fatal("Manger's attack needs 2B < n")
+	}
+	// try reports whether f*m % n < B
+	try := func(f *big.Int) bool {
+		cf := pub.EncryptInt(f)
+		cf.Mul(cf, c)
+		cf.Mod(cf, n)
+		return belowB(cf)
+	}
+
+	// Step 1: double f1 until f1*m >= B, so f1*m is in [B, 2B)
+	f1 := big.NewInt(2)
+	for try(f1) {
+		f1.Lsh(f1, 1)
+	}
+
+	// Step 2: f2*m starts in [n/2, n+B) and steps by f1/2 * m < B,
+	// so it can't skip past [n, n+B), where it wraps below B
+	half := new(big.Int).Rsh(f1, 1)
+	f2 := new(big.Int).Add(n, B)
+	f2.Div(f2, B)
+	f2.Mul(f2, half)
+	for !try(f2) {
+		f2.Add(f2, half)
+	}
+
+	// Step 3: f2*m is in [n, n+B), so m is in [n/f2, (n+B)/f2]
+	mmin := ceilDiv(n, f2)
+	mmax := floorDiv(new(big.Int).Add(n, B), f2)
+	for mmin.Cmp(mmax) < 0 {
+		ftmp := floorDiv(B2, new(big.Int).Sub(mmax, mmin))
+		i := floorDiv(new(big.Int).Mul(ftmp, mmin), n)
+		in := new(big.Int).Mul(i, n)
+		f3 := ceilDiv(in, mmin)
+		boundary := new(big.Int).Add(in, B)
+		if try(f3) {
+			mmax = floorDiv(boundary, f3)
+		} else {
+			mmin = ceilDiv(boundary, f3)
+		}
+	}
+	return leftPad(mmin.Bytes(), k)
+}
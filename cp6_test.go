@@ -0,0 +1,386 @@
+package cryptopals
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test41(t *testing.T) {
+	key := GenerateRSAKey(1024, 65537)
+	o := NewRSAOracle(key)
+	msg := `{time: 1356304276, social: '555-55-5555'}`
+	cipher := key.Encrypt([]byte(msg))
+
+	plain, err := o.Decrypt(cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalString(t, string(plain), msg)
+	if _, err = o.Decrypt(cipher); err != ErrRSAReplay {
+		t.Fatalf("got %v; want %v", err, ErrRSAReplay)
+	}
+	if _, err = o.Decrypt(append([]byte{0}, cipher...)); err != ErrRSAReplay {
+		t.Fatalf("got %v; want %v", err, ErrRSAReplay)
+	}
+	plusN := new(big.Int).Add(new(big.Int).SetBytes(cipher), key.N)
+	if _, err = o.Decrypt(plusN.Bytes()); err != ErrRSAReplay {
+		t.Fatalf("got %v; want %v", err, ErrRSAReplay)
+	}
+
+	plain, err = RSAUnpaddedRecovery(&key.RSAPublicKey, cipher, o.Decrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalString(t, string(plain), msg)
+}
+
+func Test42(t *testing.T) {
+	key := GenerateRSAKey(1024, 3)
+	pub := &key.RSAPublicKey
+	msg := []byte("hi mom")
+
+	sig := SignPKCS1v15(key, msg)
+	if !VerifyPKCS1v15(pub, msg, sig) || !VerifyPKCS1v15Sloppy(pub, msg, sig) {
+		t.Fatal("real signature did not verify")
+	}
+	if VerifyPKCS1v15(pub, []byte("hi dad"), sig) || VerifyPKCS1v15Sloppy(pub, []byte("hi dad"), sig) {
+		t.Fatal("signature verified for wrong message")
+	}
+
+	forged := ForgePKCS1v15(pub, msg)
+	if !VerifyPKCS1v15Sloppy(pub, msg, forged) {
+		t.Error("sloppy verifier rejected forgery")
+	}
+	if VerifyPKCS1v15(pub, msg, forged) {
+		t.Error("strict verifier accepted forgery")
+	}
+}
+
+func TestDSAParams(t *testing.T) {
+	p, q, g := CryptopalsDSA.P, CryptopalsDSA.Q, CryptopalsDSA.G
+	if !p.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+		t.Fatal("p or q is not prime")
+	}
+	if new(big.Int).Mod(new(big.Int).Sub(p, big.NewInt(1)), q).Sign() != 0 {
+		t.Fatal("q does not divide p - 1")
+	}
+	if new(big.Int).Exp(g, q, p).Cmp(big.NewInt(1)) != 0 {
+		t.Fatal("g does not have order q")
+	}
+}
+
+func TestDSA(t *testing.T) {
+	key := CryptopalsDSA.GenerateKey()
+	msg := []byte("hi mom")
+	sig, ok := key.Sign(msg)
+	if !ok {
+		t.Fatal("could not sign")
+	}
+	if !key.Verify(msg, sig) {
+		t.Fatal("signature did not verify")
+	}
+	if key.Verify([]byte("hi dad"), sig) {
+		t.Error("signature verified for wrong message")
+	}
+	other := CryptopalsDSA.GenerateKey()
+	if other.Verify(msg, sig) {
+		t.Error("signature verified for wrong key")
+	}
+
+	k := big.NewInt(12345)
+	h := DSAHash(msg)
+	sig, ok = key.SignWithK(h, k)
+	if !ok {
+		t.Fatal("could not sign with k")
+	}
+	if x := DSAKeyFromNonce(key.DSAParams, h, sig, k); x.Cmp(key.X) != 0 {
+		t.Errorf("recovered %v; want %v", x, key.X)
+	}
+}
+
+func Test43(t *testing.T) {
+	msg := []byte("For those that envy a MC it can be hazardous to your health\nSo be friendly, a matter of life and death, just like a etch-a-sketch\n")
+	h := DSAHash(msg)
+	equalString(t, h.Text(16), "d2d0714f014a9784047eaeccf956520045c45265")
+
+	pub := &DSAPublicKey{CryptopalsDSA, mustBigHex("84ad4719d044495496a3201c8ff484feb45b962e7302e56a392aee4abab3e4bdebf2955b4736012f21a08084056b19bcd7fee56048e004e44984e2f411788efdc837a0d2e5abb7b555039fd243ac01f0fb2ed1dec568280ce678e931868d23eb095fde9d3779191b8c0299d6e07bbb283e6633451e535c45513b2d33c99ea17")}
+	r, _ := new(big.Int).SetString("548099063082341131477253921760299949438196259240", 10)
+	s, _ := new(big.Int).SetString("857042759984254168557880549501802188789837994940", 10)
+	sig := DSASignature{r, s}
+	if !pub.Verify(msg, sig) {
+		t.Fatal("signature did not verify")
+	}
+
+	x, k, ok := BruteForceDSAKey(pub, h, sig, 1<<16)
+	if !ok {
+		t.Fatal("key not found")
+	}
+	equalString(t, DSAKeyFingerprint(x), "0954edd5e0afe5542a4adf012611a91912a3ec16")
+	key := &DSAPrivateKey{*pub, x}
+	if resig, _ := key.SignWithK(h, k); resig.R.Cmp(r) != 0 || resig.S.Cmp(s) != 0 {
+		t.Errorf("recovered key signs differently: %v", resig)
+	}
+}
+
+func TestRecordFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "# comment\na: 1\nb: x: y \r\n\na: 2\nb: 3\n")
+	f.Close()
+
+	records := mustRecordFile(f.Name())
+	if len(records) != 2 {
+		t.Fatalf("got %d records; want 2", len(records))
+	}
+	equalString(t, records[0]["b"], "x: y ")
+	equalString(t, records[1]["a"], "2")
+}
+
+func Test44(t *testing.T) {
+	key := CryptopalsDSA.GenerateKey()
+	msgs := []string{
+		"Listen for me, you better listen for me now. ",
+		"Yeah me shoes a an tear up an' now me toes is a show a ",
+		"When me rockin' the microphone me rock on steady, ",
+		"And the beat is steady and the rhythm is right ",
+		"Pure black people mon is all I mon know. ",
+		"Where me a go, me a go catch me a girl. ",
+	}
+	// Reuse one nonce for a pair of messages
+	reused := randomBelow(CryptopalsDSA.Q)
+	f, err := ioutil.TempFile("", "44.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	for i, msg := range msgs {
+		h := DSAHash([]byte(msg))
+		k := randomBelow(CryptopalsDSA.Q)
+		if i == 1 || i == 4 {
+			k = reused
+		}
+		sig, ok := key.SignWithK(h, k)
+		if !ok {
+			t.Fatal("could not sign")
+		}
+		fmt.Fprintf(f, "msg: %s\ns: %v\nr: %v\nm: %x\n", msg, sig.S, sig.R, h)
+	}
+	f.Close()
+
+	sigs := mustDSASignatureFile(f.Name())
+	if len(sigs) != len(msgs) {
+		t.Fatalf("got %d signatures; want %d", len(sigs), len(msgs))
+	}
+	for i, sig := range sigs {
+		equalString(t, string(sig.Msg), msgs[i])
+		if !key.Verify(sig.Msg, sig.Sig) {
+			t.Errorf("signature %d did not verify", i)
+		}
+	}
+
+	x, k, ok := RecoverRepeatedNonce(&key.DSAPublicKey, sigs)
+	if !ok {
+		t.Fatal("key not found")
+	}
+	if x.Cmp(key.X) != 0 || k.Cmp(reused) != 0 {
+		t.Errorf("recovered x = %v, k = %v; want %v, %v", x, k, key.X, reused)
+	}
+}
+
+func Test44Subset(t *testing.T) {
+	pub := &DSAPublicKey{CryptopalsDSA, mustBigHex("2d026f4bf30195ede3a088da85e398ef869611d0f68f0713d51c9c1a3a26c95105d915e2d8cdf26d056b86b8a7b85519b1c23cc3ecdc6062650462e3063bd179c2a6581519f674a61f1d89a1fff27171ebc1b93d4dc57bceb7ae2430f98a6a4d83d8279ee65d71c1203d2c96d65ebbf7cce9d32971c3de5084cce04a2e147821")}
+	sigs := mustDSASignatureFile("44-subset.txt")
+	if len(sigs) == 0 || !strings.HasSuffix(string(sigs[0].Msg), "now. ") {
+		t.Fatalf("bad first message: %v", sigs)
+	}
+	for i, sig := range sigs {
+		// m drops leading zeros, so compare numbers
+		if h := DSAHash(sig.Msg); h.Cmp(sig.H) != 0 {
+			t.Errorf("message %d hashes to %x; file says %x", i, h, sig.H)
+		}
+		if !pub.Verify(sig.Msg, sig.Sig) {
+			t.Errorf("signature %d did not verify", i)
+		}
+	}
+
+	x, _, ok := RecoverRepeatedNonce(pub, sigs)
+	if !ok {
+		t.Fatal("key not found")
+	}
+	equalString(t, DSAKeyFingerprint(x), "ca8f6f7c66fa362d40760d135b763eb8527d3d52")
+}
+
+func Test45(t *testing.T) {
+	key := CryptopalsDSA.GenerateKey()
+	msgs := [][]byte{[]byte("Hello, world"), []byte("Goodbye, world")}
+	tamper := func(g *big.Int) *DSAPublicKey {
+		params := *CryptopalsDSA
+		params.G = g
+		return &DSAPublicKey{&params, key.Y}
+	}
+
+	t.Run("g=0", func(t *testing.T) {
+		pub := tamper(big.NewInt(0))
+		if _, ok := (&DSAPrivateKey{*pub, key.X}).Sign(msgs[0]); ok {
+			t.Error("signed with r = 0")
+		}
+		for _, msg := range msgs {
+			sig := DSASignature{big.NewInt(0), randomBelow(pub.Q)}
+			if !pub.VerifyLax(msg, sig) {
+				t.Errorf("lax verifier rejected r = 0 for %q", msg)
+			}
+			if pub.Verify(msg, sig) {
+				t.Errorf("strict verifier accepted r = 0 for %q", msg)
+			}
+		}
+	})
+
+	t.Run("g=p+1", func(t *testing.T) {
+		pub := tamper(new(big.Int).Add(CryptopalsDSA.P, big.NewInt(1)))
+		sig := MagicDSASignature(pub, randomBelow(pub.Q))
+		for _, msg := range msgs {
+			if !pub.VerifyLax(msg, sig) {
+				t.Errorf("lax verifier rejected magic signature for %q", msg)
+			}
+			if pub.Verify(msg, sig) {
+				t.Errorf("strict verifier accepted magic signature for %q", msg)
+			}
+		}
+	})
+
+	t.Run("honest", func(t *testing.T) {
+		sig, ok := key.Sign(msgs[0])
+		if !ok || !key.Verify(msgs[0], sig) || !key.VerifyLax(msgs[0], sig) {
+			t.Error("honest signature rejected")
+		}
+	})
+}
+
+func Test46(t *testing.T) {
+	key := GenerateRSAKey(1024, 65537)
+	o := NewRSAParityOracle(key)
+	msg, err := base64.StdEncoding.DecodeString("VGhhdCdzIHdoeSBJIGZvdW5kIHlvdSBkb24ndCBwbGF5IGFyb3VuZCB3aXRoIHRoZSBGdW5reSBDb2xkIE1lZGluYQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := key.EncryptInt(new(big.Int).SetBytes(msg))
+
+	var last string
+	steps := 0
+	progress := func(upper string) {
+		steps++
+		last = upper
+		if testing.Verbose() && steps%64 == 0 {
+			t.Logf("%q", upper)
+		}
+	}
+	plain := RSAParityAttack(&key.RSAPublicKey, c, o.IsEven, progress)
+	equalString(t, string(plain), string(msg))
+	equalString(t, last, string(msg))
+	if steps != key.N.BitLen() || o.Calls != steps {
+		t.Errorf("%d steps and %d oracle calls; want %d", steps, o.Calls, key.N.BitLen())
+	}
+
+	// Small plaintexts with awkward last bytes
+	for _, m := range []string{"\x00", "\x01", "\xff", "hi\xff"} {
+		c := key.EncryptInt(new(big.Int).SetBytes([]byte(m)))
+		plain := RSAParityAttack(&key.RSAPublicKey, c, o.IsEven, nil)
+		equalBytes(t, plain, new(big.Int).SetBytes([]byte(m)).Bytes())
+	}
+}
+
+func TestPKCS1v15Pad(t *testing.T) {
+	for _, msg := range []string{"", "kick it, CC", strings.Repeat("x", 21)} {
+		em := PKCS1v15Pad([]byte(msg), 32)
+		if len(em) != 32 {
+			t.Fatalf("bad length %d", len(em))
+		}
+		unpadded, ok := PKCS1v15Unpad(em)
+		if !ok {
+			t.Fatalf("could not unpad % x", em)
+		}
+		equalString(t, string(unpadded), msg)
+	}
+	if _, ok := PKCS1v15Unpad(append([]byte{0, 2, 1, 0}, make([]byte, 28)...)); ok {
+		t.Error("unpadded short PS")
+	}
+}
+
+func Test47(t *testing.T) {
+	tcs := []struct {
+		bits int
+		msg  string
+	}{
+		{256, "kick it, CC"},
+		{768, "That's why I found you don't play around with the Funky Cold Medina"},
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprint(tc.bits), func(t *testing.T) {
+			if testing.Short() && tc.bits > 256 {
+				t.Skip("slow")
+			}
+			key := GenerateRSAKey(tc.bits, 3)
+			o := NewPKCS1v15Oracle(key)
+			c := new(big.Int).SetBytes(key.EncryptPKCS1v15([]byte(tc.msg)))
+			if !o.Conforming(c) {
+				t.Fatal("ciphertext not conforming")
+			}
+
+			em := Bleichenbacher98(&key.RSAPublicKey, c, o.Conforming)
+			plain, ok := PKCS1v15Unpad(em)
+			if !ok {
+				t.Fatalf("bad padding % x", em)
+			}
+			equalString(t, string(plain), tc.msg)
+			t.Logf("%d oracle calls", o.Calls)
+		})
+	}
+}
+
+func TestOAEP(t *testing.T) {
+	key := GenerateRSAKey(1024, 65537)
+	for _, msg := range []string{"", "kick it, CC", strings.Repeat("x", 128-2*20-2)} {
+		c := key.EncryptOAEP([]byte(msg), []byte("label"))
+		plain, err := key.DecryptOAEP(c, []byte("label"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		equalString(t, string(plain), msg)
+		if _, err = key.DecryptOAEP(c, []byte("other")); err != ErrOAEPDecoding {
+			t.Errorf("got %v; want %v", err, ErrOAEPDecoding)
+		}
+	}
+}
+
+func TestManger(t *testing.T) {
+	key := GenerateRSAKey(1024, 65537)
+	msg := "That's why I found you don't play around with the Funky Cold Medina"
+	c := new(big.Int).SetBytes(key.EncryptOAEP([]byte(msg), nil))
+
+	calls := 0
+	belowB := func(c *big.Int) bool {
+		calls++
+		_, err := key.DecryptOAEP(c.Bytes(), nil)
+		return err != ErrOAEPFirstByte
+	}
+	em := Manger(&key.RSAPublicKey, c, belowB)
+	plain, err := key.DecryptOAEP(key.Encrypt(em), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalString(t, string(plain), msg)
+	// About one query per bit, but step 2 can take up to n/B
+	// queries, and step 3 rounds worse when m >= B/2
+	if limit := 3 * key.N.BitLen() / 2; calls > limit {
+		t.Errorf("%d oracle calls; want at most %d", calls, limit)
+	}
+	t.Logf("%d oracle calls", calls)
+}
@@ -0,0 +1,292 @@
+package cryptopals
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// CBCMAC is the last block of CBCEncrypt.
+func CBCMAC(msg, key, iv []byte) []byte {
+	cipher := CBCEncrypt(msg, key, iv)
+	return cipher[len(cipher)-aes.BlockSize:]
+}
+
+var (
+	ErrTransferMAC    = errors.New("transfer: bad MAC")
+	ErrTransferFormat = errors.New("transfer: malformed request")
+)
+
+// Transfer is one movement of money recorded by a TransferServer.
+type Transfer struct {
+	From, To string
+	Amount   int
+}
+
+// TransferServer is a toy bank API that trusts any request carrying
+// a valid CBC-MAC under the key it shares with its web clients.
+// Handle takes message||IV||MAC with a client chosen IV;
+// HandleList takes message||MAC with a fixed zero IV.
+type TransferServer struct {
+	key    []byte
+	Ledger []Transfer
+}
+
+func NewTransferServer() *TransferServer {
+	return &TransferServer{key: randomBytes(16)}
+}
+
+// Client returns a web client that signs requests for the account id.
+func (s *TransferServer) Client(id string) *TransferClient {
+	return &TransferClient{ID: id, key: s.key}
+}
+
+func (s *TransferServer) Handle(req []byte) error {
+	const size = aes.BlockSize
+	if len(req) < 2*size {
+		return ErrTransferFormat
+	}
+	msg, iv, mac := req[:len(req)-2*size], req[len(req)-2*size:len(req)-size], req[len(req)-size:]
+	if subtle.ConstantTimeCompare(CBCMAC(msg, s.key, iv), mac) != 1 {
+		return ErrTransferMAC
+	}
+	var t Transfer
+	params := strings.Split(string(msg), "&")
+	if len(params) != 3 ||
+		!cutPrefix(&params[0], "from=") ||
+		!cutPrefix(&params[1], "to=") ||
+		!cutPrefix(&params[2], "amount=") {
+		return ErrTransferFormat
+	}
+	t.From, t.To = params[0], params[1]
+	amount, err := strconv.Atoi(params[2])
+	if err != nil {
+		return ErrTransferFormat
+	}
+	t.Amount = amount
+	s.Ledger = append(s.Ledger, t)
+	return nil
+}
+
+func (s *TransferServer) HandleList(req []byte) error {
+	const size = aes.BlockSize
+	if len(req) < size {
+		return ErrTransferFormat
+	}
+	msg, mac := req[:len(req)-size], req[len(req)-size:]
+	if subtle.ConstantTimeCompare(CBCMAC(msg, s.key, make([]byte, size)), mac) != 1 {
+		return ErrTransferMAC
+	}
+	params := strings.SplitN(string(msg), "&", 2)
+	if len(params) != 2 ||
+		!cutPrefix(&params[0], "from=") ||
+		!cutPrefix(&params[1], "tx_list=") {
+		return ErrTransferFormat
+	}
+	// Like many real parsers, skip what doesn't make sense
+	var txs []Transfer
+	for _, tx := range strings.Split(params[1], ";") {
+		i := strings.LastIndexByte(tx, ':')
+		if i < 0 {
+			continue
+		}
+		amount, err := strconv.Atoi(tx[i+1:])
+		if err != nil {
+			continue
+		}
+		txs = append(txs, Transfer{params[0], tx[:i], amount})
+	}
+	s.Ledger = append(s.Ledger, txs...)
+	return nil
+}
+
+// cutPrefix trims prefix from *s and reports whether it was there.
+func cutPrefix(s *string, prefix string) bool {
+	if !strings.HasPrefix(*s, prefix) {
+		return false
+	}
+	*s = (*s)[len(prefix):]
+	return true
+}
+
+// TransferClient is the web client for a logged in user.
+// It will only sign requests from its own account.
+type TransferClient struct {
+	ID  string
+	key []byte
+}
+
+func (c *TransferClient) Transfer(to string, amount int) []byte {
+	msg := []byte(fmt.Sprintf("from=%s&to=%s&amount=%d", c.ID, to, amount))
+	iv := randomBytes(aes.BlockSize)
+	mac := CBCMAC(msg, c.key, iv)
+	return append(append(msg, iv...), mac...)
+}
+
+func (c *TransferClient) TransferList(txs []Transfer) []byte {
+	list := make([]string, len(txs))
+	for i, tx := range txs {
+		list[i] = fmt.Sprintf("%s:%d", tx.To, tx.Amount)
+	}
+	msg := []byte(fmt.Sprintf("from=%s&tx_list=%s", c.ID, strings.Join(list, ";")))
+	mac := CBCMAC(msg, c.key, make([]byte, aes.BlockSize))
+	return append(msg, mac...)
+}
+
+// ForgeTransferIV rewrites the attacker's own signed request to come
+// from victim by making the same changes to the message and its IV.
+// The account names must be the same length and fit in the first block.
+func ForgeTransferIV(req []byte, victim string) ([]byte, bool) {
+	const size = aes.BlockSize
+	const prefix = "from="
+	if len(req) < 2*size || !bytes.HasPrefix(req, []byte(prefix)) {
+		return nil, false
+	}
+	msg := req[:len(req)-2*size]
+	end := bytes.IndexByte(msg, '&')
+	if end < 0 || end-len(prefix) != len(victim) || end > size {
+		return nil, false
+	}
+	attacker := string(msg[len(prefix):end])
+	forged := flipBytes(req, len(prefix), attacker, victim)
+	// Undo the change to the first block with the IV
+	ivStart := len(msg)
+	return flipBytes(forged, ivStart+len(prefix), attacker, victim), true
+}
+
+// ForgeTransferList extends a captured request from the victim with a
+// transfer of amount to the attacker. The attacker's client signs a
+// request whose first block is XORed with the captured MAC, so the
+// server's CBC state after the victim's padded message gets it back.
+// The glued garbage ends up in the victim's last transaction or in a
+// sacrificial one from the attacker to themselves.
+func ForgeTransferList(captured []byte, attacker *TransferClient, amount int) ([]byte, bool) {
+	const size = aes.BlockSize
+	if len(captured) < size {
+		return nil, false
+	}
+	msg, mac := captured[:len(captured)-size], captured[len(captured)-size:]
+	req := attacker.TransferList([]Transfer{
+		{attacker.ID, attacker.ID, 0},
+		{attacker.ID, attacker.ID, amount},
+	})
+	forged := PKCSPadding(append([]byte{}, msg...), size)
+	forged = append(forged, XorFixed(req[:size], mac)...)
+	return append(forged, req[size:]...), true
+}
+
+var cbcMACHashKey = []byte("YELLOW SUBMARINE")
+
+// CBCMACHash uses CBC-MAC with a fixed key and zero IV as a hash.
+func CBCMACHash(msg []byte) []byte {
+	return CBCMAC(msg, cbcMACHashKey, make([]byte, aes.BlockSize))
+}
+
+// ForgeCBCMACHash returns snippet followed by a line comment that
+// hashes the same as orig. Since the key is known, the comment holds a
+// forcing block that brings the CBC state back to where orig's first
+// block left it, followed by the rest of orig. Spaces are added to the
+// snippet until the forcing block has no line breaks to end the comment.
+func ForgeCBCMACHash(orig, snippet []byte) []byte {
+	const size = aes.BlockSize
+	if len(orig) < size {
+		log.Fatalf("original too short to forge: %d", len(orig))
+	}
+	for pad := ""; ; pad += " " {
+		head := PKCSPadding([]byte(string(snippet)+pad+"//"), size)
+		state := CBCMAC(head, cbcMACHashKey, make([]byte, size))
+		forcing := XorFixed(state, orig[:size])
+		if bytes.ContainsAny(forcing, "\r\n") {
+			continue
+		}
+		forged := append(head, forcing...)
+		return append(forged, orig[size:]...)
+	}
+}
+
+// CompressionOracle formats a request with a secret session cookie,
+// compresses and encrypts it under a fresh key, and reveals only the
+// length of the result.
+type CompressionOracle struct {
+	SessionID string
+	CBC       bool
+}
+
+func NewCompressionOracle(cbc bool) *CompressionOracle {
+	id := base64.StdEncoding.EncodeToString(randomBytes(32))
+	return &CompressionOracle{id, cbc}
+}
+
+func (o *CompressionOracle) formatRequest(body []byte) []byte {
+	return []byte(fmt.Sprintf("POST / HTTP/1.1\n"+
+		"Host: hapless.com\n"+
+		"Cookie: sessionid=%s\n"+
+		"Content-Length: %d\n"+
+		"%s", o.SessionID, len(body), body))
+}
+
+func (o *CompressionOracle) Length(body []byte) int {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	die(err)
+	_, err = w.Write(o.formatRequest(body))
+	die(err)
+	die(w.Close())
+
+	key := randomBytes(16)
+	if o.CBC {
+		return len(CBCEncrypt(buf.Bytes(), key, randomBytes(16)))
+	}
+	nonce := binary.LittleEndian.Uint64(randomBytes(8))
+	return len(CTR(buf.Bytes(), key, nonce))
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// CRIME recovers the n characters that follow prefix in the request
+// by guessing one base64 character at a time. A correct guess extends
+// the match against the secret, so known+guess+sep compresses smaller
+// than known+sep+guess, while wrong guesses come out exactly the same
+// either way. Filler bytes that never repeat are added in front until
+// the difference crosses a byte or, for CBC, a block boundary.
+func CRIME(length func([]byte) int, prefix string, n int) (string, bool) {
+	const sep = "~!"
+	known := prefix
+	// The last filler that worked will likely work again
+	start := 0
+	for len(known) < len(prefix)+n {
+		var guess byte
+		for tries := 0; guess == 0 && tries < 0x80; tries++ {
+			filler := (start + tries) % 0x80
+			pad := make([]byte, filler)
+			for i := range pad {
+				pad[i] = byte(0x80 + i)
+			}
+			best := 0
+			for _, c := range []byte(base64Alphabet) {
+				head := string(pad) + known
+				diff := length([]byte(head+sep+string(c))) -
+					length([]byte(head+string(c)+sep))
+				if diff > best {
+					best, guess = diff, c
+				}
+			}
+			if guess != 0 {
+				start = filler
+			}
+		}
+		if guess == 0 {
+			return known[len(prefix):], false
+		}
+		known += string(guess)
+	}
+	return known[len(prefix):], true
+}
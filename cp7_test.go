@@ -0,0 +1,113 @@
+package cryptopals
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCBCMAC(t *testing.T) {
+	key, iv := randomBytes(16), randomBytes(16)
+	msg := []byte("alert('MZA who was that?');\n")
+	cipher := CBCEncrypt(msg, key, iv)
+	equalBytes(t, CBCMAC(msg, key, iv), cipher[len(cipher)-16:])
+}
+
+func Test49(t *testing.T) {
+	s := NewTransferServer()
+	mallory := s.Client("mallory")
+
+	t.Run("IV", func(t *testing.T) {
+		s.Ledger = nil
+		req := mallory.Transfer("mallory", 1000000)
+		if err := s.Handle(req); err != nil {
+			t.Fatal(err)
+		}
+		forged, ok := ForgeTransferIV(req, "alice01")
+		if !ok {
+			t.Fatal("could not forge request")
+		}
+		if err := s.Handle(forged); err != nil {
+			t.Fatal(err)
+		}
+		want := Transfer{"alice01", "mallory", 1000000}
+		if len(s.Ledger) != 2 || s.Ledger[1] != want {
+			t.Errorf("got %v; want %v", s.Ledger, want)
+		}
+		if _, ok := ForgeTransferIV(req, "bob"); ok {
+			t.Error("forged name of different length")
+		}
+	})
+
+	t.Run("length extension", func(t *testing.T) {
+		s.Ledger = nil
+		captured := s.Client("alice").TransferList([]Transfer{
+			{"alice", "bob", 10},
+			{"alice", "carol", 20},
+		})
+		if err := s.HandleList(captured); err != nil {
+			t.Fatal(err)
+		}
+		forged, ok := ForgeTransferList(captured, mallory, 1000000)
+		if !ok {
+			t.Fatal("could not forge request")
+		}
+		s.Ledger = nil
+		if err := s.HandleList(forged); err != nil {
+			t.Fatal(err)
+		}
+		want := Transfer{"alice", "mallory", 1000000}
+		if len(s.Ledger) == 0 || s.Ledger[len(s.Ledger)-1] != want {
+			t.Errorf("got %q; want %v", s.Ledger, want)
+		}
+		if s.Ledger[0] != (Transfer{"alice", "bob", 10}) {
+			t.Errorf("victim's transfers lost: %q", s.Ledger)
+		}
+	})
+
+	t.Run("tampering", func(t *testing.T) {
+		req := mallory.Transfer("mallory", 1)
+		req[len(req)-33]++
+		if err := s.Handle(req); err != ErrTransferMAC {
+			t.Errorf("got %v; want %v", err, ErrTransferMAC)
+		}
+		req = mallory.TransferList([]Transfer{{"mallory", "mallory", 1}})
+		req[len(req)-17]++
+		if err := s.HandleList(req); err != ErrTransferMAC {
+			t.Errorf("got %v; want %v", err, ErrTransferMAC)
+		}
+	})
+}
+
+func Test50(t *testing.T) {
+	orig := []byte("alert('MZA who was that?');\n")
+	snippet := []byte("alert('Ayo, the Wu is back!');")
+	forged := ForgeCBCMACHash(orig, snippet)
+	equalBytes(t, CBCMACHash(forged), CBCMACHash(orig))
+	if !bytes.HasPrefix(forged, snippet) {
+		t.Errorf("snippet missing: %q", forged)
+	}
+	// The rest stays commented out until orig's newline
+	code := forged[:bytes.IndexAny(forged, "\r\n")]
+	comment := bytes.Index(code, []byte("//"))
+	if comment < 0 || strings.TrimSpace(string(code[len(snippet):comment])) != "" {
+		t.Errorf("bad forgery: %q", forged)
+	}
+}
+
+func Test51(t *testing.T) {
+	for _, cbc := range []bool{false, true} {
+		o := NewCompressionOracle(cbc)
+		calls := 0
+		length := func(body []byte) int {
+			calls++
+			return o.Length(body)
+		}
+		got, ok := CRIME(length, "sessionid=", len(o.SessionID))
+		t.Logf("cbc=%v: %d oracle calls", cbc, calls)
+		if !ok {
+			t.Error("no guess compressed smaller")
+		}
+		equalString(t, got, o.SessionID)
+	}
+}